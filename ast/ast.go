@@ -3,6 +3,7 @@ package ast
 import (
 	"Nutlang/token"
 	"bytes"
+	"regexp"
 	"strings"
 )
 
@@ -46,7 +47,10 @@ func (p *Program) String() string {
 type AssignmentExpression struct {
 	Token token.Token
 	Left  Expression
-	Value Expression
+	// Operator is the infix operator of a compound assignment, "+" for
+	// x += y, and empty for a plain one
+	Operator string
+	Value    Expression
 }
 
 func (ae *AssignmentExpression) expressionNode()      {}
@@ -66,6 +70,7 @@ func (ae *AssignmentExpression) String() string {
 type HashLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression // the keys of Pairs, in source order
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -74,8 +79,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -85,6 +90,17 @@ func (hl *HashLiteral) String() string {
 	return out.String()
 }
 
+// SpreadElement is ...rest, collecting the remaining elements of an
+// array in a destructuring pattern.
+type SpreadElement struct {
+	Token token.Token // The ... token
+	Value Expression
+}
+
+func (se *SpreadElement) expressionNode()      {}
+func (se *SpreadElement) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadElement) String() string       { return "..." + se.Value.String() }
+
 type IndexExpression struct {
 	Token token.Token // The [ token
 	Left  Expression
@@ -136,10 +152,55 @@ func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// InterpolatedString is a string literal with embedded expressions, such
+// as "pos=${x},${y}". Parts holds the *StringLiteral segments and the
+// embedded expressions in source order.
+type InterpolatedString struct {
+	Token token.Token // the token.INTERP_START token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, part := range is.Parts {
+		if sl, ok := part.(*StringLiteral); ok {
+			out.WriteString(sl.Value)
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+
+	return out.String()
+}
+
+type CharLiteral struct {
+	Token token.Token
+	Value rune
+}
+
+func (cl *CharLiteral) expressionNode()      {}
+func (cl *CharLiteral) TokenLiteral() string { return cl.Token.Literal }
+func (cl *CharLiteral) String() string       { return "'" + cl.Token.Literal + "'" }
+
+type RegexLiteral struct {
+	Token token.Token
+	Value *regexp.Regexp
+}
+
+func (rl *RegexLiteral) expressionNode()      {}
+func (rl *RegexLiteral) TokenLiteral() string { return rl.Token.Literal }
+func (rl *RegexLiteral) String() string       { return "r\"" + rl.Token.Literal + "\"" }
+
 type LetStatement struct {
 	Value Expression
 	Name  *Identifier
-	Token token.Token
+	// Pattern, set instead of Name, is the array or hash literal whose
+	// identifiers are bound to the parts of Value, as in let [x, y] = p
+	Pattern Expression
+	Token   token.Token
 }
 
 func (ls *LetStatement) statementNode()       {}
@@ -148,7 +209,11 @@ func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -177,6 +242,17 @@ type ReturnStatement struct {
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
 
+type ThrowStatement struct {
+	Value Expression
+	Token token.Token
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) String() string {
+	return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
+
 type ExpressionStatement struct {
 	Expression Expression
 	Token      token.Token
@@ -263,6 +339,36 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+type PostfixExpression struct {
+	Left     Expression
+	Token    token.Token
+	Operator string
+}
+
+func (pe *PostfixExpression) expressionNode()      {}
+func (pe *PostfixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PostfixExpression) String() string {
+	return "(" + pe.Left.String() + pe.Operator + ")"
+}
+
+// IncrementExpression is ++x, x++, --x or x--. Prefix ones evaluate to
+// the updated value and postfix ones to the previous value.
+type IncrementExpression struct {
+	Token    token.Token
+	Operator string
+	Target   Expression
+	Prefix   bool
+}
+
+func (ie *IncrementExpression) expressionNode()      {}
+func (ie *IncrementExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IncrementExpression) String() string {
+	if ie.Prefix {
+		return "(" + ie.Operator + ie.Target.String() + ")"
+	}
+	return "(" + ie.Target.String() + ie.Operator + ")"
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -326,7 +432,9 @@ type IfExpression struct {
 	Condition   Expression
 	Consequence *BlockStatement
 	Alternative *BlockStatement
-	Token       token.Token
+	// ElseIf, set instead of Alternative, is the if of an else if
+	ElseIf *IfExpression
+	Token  token.Token
 }
 
 func (ie *IfExpression) expressionNode()      {}
@@ -342,6 +450,121 @@ func (ie *IfExpression) String() string {
 	if ie.Alternative != nil {
 		out.WriteString("else ")
 		out.WriteString(ie.Alternative.String())
+	} else if ie.ElseIf != nil {
+		out.WriteString("else ")
+		out.WriteString(ie.ElseIf.String())
+	}
+
+	return out.String()
+}
+
+// ConditionalExpression is Condition ? Consequence : Alternative.
+type ConditionalExpression struct {
+	Token       token.Token // The ? token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (ce *ConditionalExpression) expressionNode()      {}
+func (ce *ConditionalExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ConditionalExpression) String() string {
+	return "(" + ce.Condition.String() + " ? " + ce.Consequence.String() + " : " + ce.Alternative.String() + ")"
+}
+
+// MatchExpression is match (Value) { pattern => result, ... }. It
+// evaluates the result of the first arm whose pattern matches Value.
+type MatchExpression struct {
+	Token token.Token // The match token
+	Value Expression
+	Arms  []*MatchArm
+}
+
+// MatchArm is Pattern if Guard => Result, where Guard may be missing and
+// Result is an expression or a block.
+//
+// Patterns are literals, ranges, _, identifiers binding the value, array
+// and hash literals of patterns, and alternatives.
+type MatchArm struct {
+	Pattern Expression
+	Guard   Expression
+	Result  Node
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		s := arm.Pattern.String()
+		if arm.Guard != nil {
+			s += " if " + arm.Guard.String()
+		}
+		arms = append(arms, s+" => "+arm.Result.String())
+	}
+	return "match (" + me.Value.String() + ") { " + strings.Join(arms, ", ") + " }"
+}
+
+// RangePattern is the pattern Low..High, or Low..=High when Inclusive,
+// matching the values between them.
+type RangePattern struct {
+	Token     token.Token // The .. or ..= token
+	Low       Expression
+	High      Expression
+	Inclusive bool
+}
+
+func (rp *RangePattern) expressionNode()      {}
+func (rp *RangePattern) TokenLiteral() string { return rp.Token.Literal }
+func (rp *RangePattern) String() string {
+	return rp.Low.String() + rp.Token.Literal + rp.High.String()
+}
+
+// AlternativePattern is a | b | c, matching any of the Patterns.
+type AlternativePattern struct {
+	Token    token.Token // The first | token
+	Patterns []Expression
+}
+
+func (ap *AlternativePattern) expressionNode()      {}
+func (ap *AlternativePattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *AlternativePattern) String() string {
+	patterns := []string{}
+	for _, pattern := range ap.Patterns {
+		patterns = append(patterns, pattern.String())
+	}
+	return strings.Join(patterns, " | ")
+}
+
+// TryExpression is try { Body } catch (Param) { Catch } finally { Finally },
+// where either the catch or the finally clause may be missing, as well
+// as the catch parameter.
+type TryExpression struct {
+	Token   token.Token
+	Body    *BlockStatement
+	Param   *Identifier
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Body.String())
+
+	if te.Catch != nil {
+		out.WriteString(" catch ")
+		if te.Param != nil {
+			out.WriteString("(" + te.Param.String() + ") ")
+		}
+		out.WriteString(te.Catch.String())
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
 	}
 
 	return out.String()
@@ -365,8 +588,11 @@ func (bs *BlockStatement) String() string {
 }
 
 type FunctionLiteral struct {
-	Token      token.Token // The 'fn' token
-	Parameters []*Identifier
+	Token token.Token // The 'fn' token
+	// Name is the name of a declared function, and empty otherwise
+	Name string
+	// Parameters are identifiers or destructuring patterns
+	Parameters []Expression
 	Body       *BlockStatement
 }
 
@@ -381,6 +607,9 @@ func (fl *FunctionLiteral) String() string {
 	}
 
 	out.WriteString(fl.TokenLiteral())
+	if fl.Name != "" {
+		out.WriteString(" " + fl.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
@@ -389,6 +618,18 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// FunctionStatement is the declaration fn name(params) { body }, which
+// defines name from the start of the enclosing program or block.
+type FunctionStatement struct {
+	Token    token.Token // The 'fn' token
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) statementNode()       {}
+func (fs *FunctionStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *FunctionStatement) String() string       { return fs.Function.String() }
+
 type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
@@ -0,0 +1,288 @@
+package evaluator
+
+import (
+	"Nutlang/ast"
+	"Nutlang/object"
+	"Nutlang/token"
+)
+
+// target is the variable or element an assignment stores to. The
+// container and index of an element are evaluated once, so that
+// counts[key()] += 1 calls key a single time.
+type target struct {
+	name      *ast.Identifier
+	container object.Object
+	index     object.Object
+}
+
+func evalTarget(exp ast.Expression, env *object.Environment) (*target, object.Object) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if env.IsConst(exp.Value) {
+			return nil, newError("cannot assign to constant %s", exp.Value)
+		}
+		return &target{name: exp}, nil
+
+	case *ast.IndexExpression:
+		container := Eval(exp.Left, env)
+		if isAbrupt(container) {
+			return nil, container
+		}
+		index := Eval(exp.Index, env)
+		if isAbrupt(index) {
+			return nil, index
+		}
+		return &target{container: container, index: index}, nil
+	}
+
+	return nil, newError("expected identifier or index expression got=%T", exp)
+}
+
+// get returns the current value of the target, failing if it does not
+// exist yet.
+func (t *target) get(env *object.Environment) object.Object {
+	if t.name != nil {
+		return evalIdentifier(t.name, env)
+	}
+	if array, ok := t.container.(*object.Array); ok {
+		idx, err := arrayIndex(array, t.index)
+		if err != nil {
+			return err
+		}
+		return array.Elements[idx]
+	}
+	return evalIndexExpression(t.container, t.index)
+}
+
+// set stores val in the target, returning an error or nil.
+func (t *target) set(env *object.Environment, val object.Object) object.Object {
+	if t.name != nil {
+		env.Set(t.name.Value, val)
+		return nil
+	}
+
+	if err := checkMutable(t.container); err != nil {
+		return err
+	}
+
+	switch container := t.container.(type) {
+	case *object.Array:
+		idx, err := arrayIndex(container, t.index)
+		if err != nil {
+			return err
+		}
+		container.Elements[idx] = val
+	case *object.Hash:
+		if !container.Set(t.index, val) {
+			return newError("unusable as hash key: %s", t.index.Type())
+		}
+	default:
+		return newError("object type %s does not support item assignment", t.container.Type())
+	}
+	return nil
+}
+
+// arrayIndex checks that index is an INTEGER within the bounds of array.
+func arrayIndex(array *object.Array, index object.Object) (int64, *object.Error) {
+	idx, ok := index.(*object.Integer)
+	if !ok {
+		return 0, newError("index operator not supported: %s", array.Type())
+	}
+	if idx.Value < 0 || idx.Value >= int64(len(array.Elements)) {
+		return 0, newError("index %d out of bounds in array of length %d",
+			idx.Value, len(array.Elements))
+	}
+	return idx.Value, nil
+}
+
+// evalAssignmentExpression evaluates x = y and compound assignments such
+// as x += y, which stores x + y in x.
+func evalAssignmentExpression(node *ast.AssignmentExpression, env *object.Environment) object.Object {
+	if node.Token.Type == token.BIND {
+		return evalBindExpression(node, env)
+	}
+	if _, ok := node.Left.(*ast.Identifier); !ok {
+		if _, ok := node.Left.(*ast.IndexExpression); !ok {
+			return evalDestructuringAssignment(node, env)
+		}
+	}
+
+	t, err := evalTarget(node.Left, env)
+	if err != nil {
+		return err
+	}
+
+	var current object.Object
+	if node.Operator != "" || t.name != nil {
+		// Plain assignments too require the variable to be declared
+		current = t.get(env)
+		if isAbrupt(current) {
+			return current
+		}
+	}
+
+	val := Eval(node.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	if node.Operator != "" {
+		val = allocated(env, evalInfixExpression(node.Operator, current, val))
+		if isAbrupt(val) {
+			return val
+		}
+	}
+
+	if err := t.set(env, val); err != nil {
+		return err
+	}
+	return val
+}
+
+// evalIncrementExpression evaluates ++x, x++, --x and x--.
+func evalIncrementExpression(node *ast.IncrementExpression, env *object.Environment) object.Object {
+	t, err := evalTarget(node.Target, env)
+	if err != nil {
+		return err
+	}
+
+	current := t.get(env)
+	if isAbrupt(current) {
+		return current
+	}
+
+	switch current.Type() {
+	case object.INTEGER_OBJ, object.FLOAT_OBJ, object.CHAR_OBJ:
+	default:
+		return newError("unknown operator: %s%s", node.Operator, current.Type())
+	}
+
+	val := allocated(env, evalInfixExpression(node.Operator[:1], current, &object.Integer{Value: 1}))
+	if isAbrupt(val) {
+		return val
+	}
+	if err := t.set(env, val); err != nil {
+		return err
+	}
+
+	if node.Prefix {
+		return val
+	}
+	return current
+}
+
+// evalBindExpression evaluates x := y, which declares x like let x = y
+// but evaluates to y.
+func evalBindExpression(node *ast.AssignmentExpression, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	if err := destructure(node.Left, val, env, declare(env, false)); err != nil {
+		return err
+	}
+	return val
+}
+
+// evalDestructuringAssignment evaluates [a, b] = [b, a], assigning the
+// parts of the value to existing variables and elements.
+func evalDestructuringAssignment(node *ast.AssignmentExpression, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	err := destructure(node.Left, val, env, func(exp ast.Expression, val object.Object) object.Object {
+		t, err := evalTarget(exp, env)
+		if err != nil {
+			return err
+		}
+		if t.name != nil {
+			if current := t.get(env); isAbrupt(current) {
+				return current
+			}
+		}
+		return t.set(env, val)
+	})
+	if err != nil {
+		return err
+	}
+	return val
+}
+
+// declare returns a store for destructure binding identifiers in env.
+func declare(env *object.Environment, constant bool) func(ast.Expression, object.Object) object.Object {
+	return func(exp ast.Expression, val object.Object) object.Object {
+		name := exp.(*ast.Identifier).Value
+		if env.IsLocalConst(name) {
+			return newError("cannot redeclare constant %s", name)
+		}
+		if constant {
+			env.SetConst(name, val)
+		} else {
+			env.Set(name, val)
+		}
+		return nil
+	}
+}
+
+// destructure matches val against pattern, an identifier, index
+// expression or array or hash literal of patterns, and calls store with
+// each leaf of the pattern and its part of val. Missing elements and keys
+// are null. It returns an error or nil.
+func destructure(
+	pattern ast.Expression,
+	val object.Object,
+	env *object.Environment,
+	store func(ast.Expression, object.Object) object.Object,
+) object.Object {
+	switch pattern := pattern.(type) {
+	case *ast.ArrayLiteral:
+		array, ok := val.(*object.Array)
+		if !ok {
+			return newError("cannot destructure %s as ARRAY", val.Type())
+		}
+
+		for i, elem := range pattern.Elements {
+			if spread, ok := elem.(*ast.SpreadElement); ok {
+				rest := []object.Object{}
+				if i < len(array.Elements) {
+					rest = append(rest, array.Elements[i:]...)
+				}
+				return destructure(spread.Value, &object.Array{Elements: rest}, env, store)
+			}
+
+			var item object.Object = NULL
+			if i < len(array.Elements) {
+				item = array.Elements[i]
+			}
+			if err := destructure(elem, item, env, store); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *ast.HashLiteral:
+		if _, ok := val.(*object.Hash); !ok {
+			return newError("cannot destructure %s as HASH", val.Type())
+		}
+
+		for _, keyNode := range pattern.Keys {
+			key := Eval(keyNode, env)
+			if isAbrupt(key) {
+				return key
+			}
+			item := evalHashIndexExpression(val, key)
+			if isError(item) {
+				return item
+			}
+			if err := destructure(pattern.Pairs[keyNode], item, env, store); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return store(pattern, val)
+}
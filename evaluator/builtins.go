@@ -2,15 +2,25 @@ package evaluator
 
 import (
 	"Nutlang/object"
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"math"
 	mrand "math/rand"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -20,7 +30,9 @@ var builtins = map[string]*object.Builtin{
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(arg.Len())}
 			default:
 				return newError("argument to `len` not supported, got %s",
 					args[0].Type())
@@ -28,7 +40,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"readFile": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -36,9 +48,12 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.String:
+				if err := permitPath(ctx, "readFile", arg.Value, object.FS_READ_CAP); err != nil {
+					return err
+				}
 				fd, err := os.ReadFile(arg.Value)
 				if err != nil {
-					return newError("error on read file, %s", err)
+					return newKindError(object.IO_ERROR, "error on read file, %s", err)
 				}
 				return &object.String{Value: string(fd)}
 			default:
@@ -47,8 +62,415 @@ var builtins = map[string]*object.Builtin{
 			}
 		},
 	},
+	"open": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			strs, err := stringArgs("open", args)
+			if err != nil {
+				return err
+			}
+
+			mode := "r"
+			if len(strs) == 2 {
+				mode = strs[1]
+			}
+			return openFile(ctx, "open", strs[0], mode)
+		},
+	},
+	"readLine": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			// readLine() reads from standard input
+			if len(args) == 0 {
+				return readStdinLine(ctx, "readLine")
+			}
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=0 or 1",
+					len(args))
+			}
+			fd, err := fileArg("readLine", args, 0)
+			if err != nil {
+				return err
+			}
+			return readLine("readLine", fd.Reader)
+		},
+	},
+	"readAll": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			fd, err := fileArg("readAll", args, 0)
+			if err != nil {
+				return err
+			}
+
+			data, readErr := io.ReadAll(fd.Reader)
+			if readErr != nil {
+				return ioError("readAll", readErr)
+			}
+			return &object.String{Value: string(data)}
+		},
+	},
+	"readStdin": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+			return readStdin(ctx)
+		},
+	},
+	"stdinLines": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+			return stdinLineIterator(ctx)
+		},
+	},
+	"lines": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			// lines(path) opens the file, which is closed after its last line
+			if path, ok := args[0].(*object.String); ok {
+				fd := openFile(ctx, "lines", path.Value, "r")
+				if isError(fd) {
+					return fd
+				}
+				return lineIterator(ctx, fd.(*object.FileDescriptor))
+			}
+
+			if _, ok := args[0].(*object.FileDescriptor); !ok {
+				return newError("argument to `lines` must be STRING or FD, got %s",
+					args[0].Type())
+			}
+			fd, err := fileArg("lines", args, 0)
+			if err != nil {
+				return err
+			}
+			return lineIterator(ctx, fd)
+		},
+	},
+	"write": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			fd, err := fileArg("write", args, 0)
+			if err != nil {
+				return err
+			}
+
+			n, writeErr := writeFile(fd, args[1].Inspect())
+			if writeErr != nil {
+				return ioError("write", writeErr)
+			}
+			return &object.Integer{Value: int64(n)}
+		},
+	},
+	"close": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if _, ok := args[0].(*object.FileDescriptor); !ok {
+				return newError("argument to `close` must be FD, got %s",
+					args[0].Type())
+			}
+			return closeFile(ctx, args[0].(*object.FileDescriptor))
+		},
+	},
+	"writeFile": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			path, err := stringArg("writeFile", args, 0)
+			if err != nil {
+				return err
+			}
+			if err := permitPath(ctx, "writeFile", path, object.FS_WRITE_CAP); err != nil {
+				return err
+			}
+
+			if err := os.WriteFile(path, []byte(args[1].Inspect()), 0o644); err != nil {
+				return ioError("writeFile", err)
+			}
+			return NULL
+		},
+	},
+	"appendFile": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			path, err := stringArg("appendFile", args, 0)
+			if err != nil {
+				return err
+			}
+			if err := permitPath(ctx, "appendFile", path, object.FS_WRITE_CAP); err != nil {
+				return err
+			}
+
+			f, openErr := os.OpenFile(path, openModes["a"], 0o644)
+			if openErr != nil {
+				return ioError("appendFile", openErr)
+			}
+			defer f.Close()
+
+			if _, err := io.WriteString(f, args[1].Inspect()); err != nil {
+				return ioError("appendFile", err)
+			}
+			return NULL
+		},
+	},
+	"exists": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			path, err := stringArg("exists", args, 0)
+			if err != nil {
+				return err
+			}
+			if err := permitPath(ctx, "exists", path, object.FS_READ_CAP); err != nil {
+				return err
+			}
+
+			_, statErr := os.Stat(path)
+			if statErr != nil && !errors.Is(statErr, fs.ErrNotExist) {
+				return ioError("exists", statErr)
+			}
+			return nativeBoolToBooleanObject(statErr == nil)
+		},
+	},
+	"listDir": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			path, err := stringArg("listDir", args, 0)
+			if err != nil {
+				return err
+			}
+			if err := permitPath(ctx, "listDir", path, object.FS_READ_CAP); err != nil {
+				return err
+			}
+
+			entries, readErr := os.ReadDir(path)
+			if readErr != nil {
+				return ioError("listDir", readErr)
+			}
+			names := make([]string, len(entries))
+			for i, entry := range entries {
+				names[i] = entry.Name()
+			}
+			return stringsToArray(names)
+		},
+	},
+	"mkdir": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			path, err := stringArg("mkdir", args, 0)
+			if err != nil {
+				return err
+			}
+			if err := permitPath(ctx, "mkdir", path, object.FS_WRITE_CAP); err != nil {
+				return err
+			}
+
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return ioError("mkdir", err)
+			}
+			return NULL
+		},
+	},
+	"removeFile": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			path, err := stringArg("removeFile", args, 0)
+			if err != nil {
+				return err
+			}
+			if err := permitPath(ctx, "removeFile", path, object.FS_WRITE_CAP); err != nil {
+				return err
+			}
+
+			if err := os.Remove(path); err != nil {
+				return ioError("removeFile", err)
+			}
+			return NULL
+		},
+	},
+	"args": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+			return stringsToArray(ctx.Runtime().Args)
+		},
+	},
+	"now": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+			if err := permit(ctx, "now", object.TIME_CAP); err != nil {
+				return err
+			}
+			return &object.Integer{Value: time.Now().UnixMilli()}
+		},
+	},
+	"sleep": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			ms, err := integerArg("sleep", args, 0)
+			if err != nil {
+				return err
+			}
+			if err := permit(ctx, "sleep", object.TIME_CAP); err != nil {
+				return err
+			}
+
+			return sleep(ctx, time.Duration(ms)*time.Millisecond)
+		},
+	},
+	"error": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			strs, err := stringArgs("error", args)
+			if err != nil {
+				return err
+			}
+
+			kind := object.ERROR
+			if len(strs) == 2 {
+				kind = strs[1]
+			}
+			return &object.Exception{Error: &object.Error{Message: strs[0], Kind: kind}}
+		},
+	},
+	"isError": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			return nativeBoolToBooleanObject(args[0].Type() == object.EXCEPTION_OBJ)
+		},
+	},
+	"tryCall": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1",
+					len(args))
+			}
+
+			// Errors are returned as [null, error] instead of being raised,
+			// except for limit errors which cannot be caught
+			result := ctx.Call(args[0], args[1:]...)
+			switch result := result.(type) {
+			case *object.Error:
+				if !catchable(result) {
+					return result
+				}
+				return &object.Array{Elements: []object.Object{NULL, &object.Exception{Error: result}}}
+			case *object.Exception:
+				return &object.Array{Elements: []object.Object{NULL, result}}
+			}
+			return &object.Array{Elements: []object.Object{result, NULL}}
+		},
+	},
+	"freeze": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			freeze(args[0])
+			return args[0]
+		},
+	},
+	"isFrozen": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			return nativeBoolToBooleanObject(checkMutable(args[0]) != nil)
+		},
+	},
+	"next": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			it, ok := args[0].(*object.Iterator)
+			if !ok {
+				return newError("argument to `next` must be ITERATOR, got %s",
+					args[0].Type())
+			}
+
+			if value, ok := it.Next(); ok {
+				return value
+			}
+			return NULL
+		},
+	},
+	"collect": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			it, ok := args[0].(*object.Iterator)
+			if !ok {
+				return newError("argument to `collect` must be ITERATOR, got %s",
+					args[0].Type())
+			}
+
+			elements := []object.Object{}
+			for value, ok := it.Next(); ok; value, ok = it.Next() {
+				if isError(value) {
+					return value
+				}
+				elements = append(elements, value)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
 	"min": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2",
 					len(args))
@@ -70,7 +492,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"max": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2",
 					len(args))
@@ -92,15 +514,21 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rand": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if err := permit(ctx, "rand", object.RANDOM_CAP); err != nil {
+				return err
+			}
 			if len(args) == 0 {
 				return &object.Integer{Value: int64(mrand.Int())}
 			} else if len(args) == 1 {
 				if args[0].Type() == object.INTEGER_OBJ {
 					arg := args[0].(*object.Integer).Value
+					if arg <= 0 {
+						return newError("argument to `rand` must be positive, got %d", arg)
+					}
 					return &object.Integer{Value: int64(mrand.Int63n(arg))}
 				}
-				return newError("argument to `first` must be INTEGER, got %s",
+				return newError("argument to `rand` must be INTEGER, got %s",
 					args[0].Type())
 			} else {
 				return newError("wrong number of arguments. got=%d, want=0 or 1",
@@ -109,7 +537,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"first": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -128,7 +556,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -148,7 +576,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -170,7 +598,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2",
 					len(args))
@@ -191,7 +619,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"pop": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -216,7 +644,7 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"remove": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2",
 					len(args))
@@ -254,7 +682,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"unshift": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2",
 					len(args))
@@ -326,7 +754,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"shift": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -350,7 +778,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"includes": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2",
 					len(args))
@@ -358,29 +786,21 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.Array:
-				switch arg2 := args[1].(type) {
-				case *object.Integer:
-					for _, v := range arg.Elements {
-						if v.Type() == object.INTEGER_OBJ && v.(*object.Integer).Value == arg2.Value {
-							return &object.Boolean{Value: true}
-						}
-					}
-				case *object.String:
-					for _, v := range arg.Elements {
-						if v.Type() == object.STRING_OBJ && v.(*object.String).Value == arg2.Value {
-							return &object.Boolean{Value: true}
-						}
+				for _, v := range arg.Elements {
+					if object.Equals(v, args[1]) {
+						return TRUE
 					}
-				default:
-					return newError("argument 2 to `includes` not supported, got %s",
-						args[1].Type())
 				}
 			case *object.String:
 
 				switch arg2 := args[1].(type) {
 				case *object.String:
 					if strings.Contains(arg.Value, arg2.Value) {
-						return &object.Boolean{Value: true}
+						return TRUE
+					}
+				case *object.Char:
+					if strings.ContainsRune(arg.Value, arg2.Value) {
+						return TRUE
 					}
 				default:
 					return newError("argument 2 to `includes` not supported, got %s",
@@ -390,12 +810,12 @@ var builtins = map[string]*object.Builtin{
 				return newError("argument 1 to `includes` not supported, got %s",
 					args[0].Type())
 			}
-			return &object.Boolean{Value: false}
+			return FALSE
 		},
 	},
 
 	"trim": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2",
 					len(args))
@@ -419,7 +839,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"split": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2",
 					len(args))
@@ -446,27 +866,1517 @@ var builtins = map[string]*object.Builtin{
 			}
 		},
 	},
-	"puts": {
-		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+	"keys": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to `keys` must be HASH, got %s",
+					args[0].Type())
 			}
 
-			return NULL
+			pairs := args[0].(*object.Hash).Pairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Key
+			}
+			return &object.Array{Elements: elements}
 		},
 	},
-}
+	"values": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to `values` must be HASH, got %s",
+					args[0].Type())
+			}
 
-func min(a, b int64) int64 {
-	if a < b {
-		return a
-	}
-	return b
-}
+			pairs := args[0].(*object.Hash).Pairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Value
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"entries": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to `entries` must be HASH, got %s",
+					args[0].Type())
+			}
 
-func max(a, b int64) int64 {
-	if a > b {
-		return a
-	}
-	return b
+			pairs := args[0].(*object.Hash).Pairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"fromEntries": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `fromEntries` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			hash := object.NewHash()
+			for i, el := range args[0].(*object.Array).Elements {
+				entry, ok := el.(*object.Array)
+				if !ok || len(entry.Elements) != 2 {
+					return newError("entry %d to `fromEntries` must be a [key, value] ARRAY, got %s",
+						i, el.Inspect())
+				}
+				if !hash.Set(entry.Elements[0], entry.Elements[1]) {
+					return newError("unusable as hash key: %s", entry.Elements[0].Type())
+				}
+			}
+			return hash
+		},
+	},
+	"has": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument 1 to `has` must be HASH, got %s",
+					args[0].Type())
+			}
+			if _, ok := object.HashKeyOf(args[1]); !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			_, ok := args[0].(*object.Hash).Get(args[1])
+			return nativeBoolToBooleanObject(ok)
+		},
+	},
+	"delete": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument 1 to `delete` must be HASH, got %s",
+					args[0].Type())
+			}
+			if _, ok := object.HashKeyOf(args[1]); !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+			if err := checkMutable(args[0]); err != nil {
+				return err
+			}
+
+			return nativeBoolToBooleanObject(args[0].(*object.Hash).Delete(args[1]))
+		},
+	},
+	"getOr": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3",
+					len(args))
+			}
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument 1 to `getOr` must be HASH, got %s",
+					args[0].Type())
+			}
+			if _, ok := object.HashKeyOf(args[1]); !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			if value, ok := args[0].(*object.Hash).Get(args[1]); ok {
+				return value
+			}
+			return args[2]
+		},
+	},
+	"merge": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1",
+					len(args))
+			}
+
+			merged := object.NewHash()
+			for i, arg := range args {
+				hash, ok := arg.(*object.Hash)
+				if !ok {
+					return newError("argument %d to `merge` must be HASH, got %s",
+						i+1, arg.Type())
+				}
+				for _, pair := range hash.Pairs() {
+					merged.Set(pair.Key, pair.Value)
+				}
+			}
+			return merged
+		},
+	},
+	"map": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument 1 to `map` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			elements := make([]object.Object, len(arr.Elements))
+			for i, el := range arr.Elements {
+				result := callWithIndex(ctx, args[1], i, el)
+				if isError(result) {
+					return result
+				}
+				elements[i] = result
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"filter": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument 1 to `filter` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			elements := []object.Object{}
+			for i, el := range args[0].(*object.Array).Elements {
+				result := callWithIndex(ctx, args[1], i, el)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					elements = append(elements, el)
+				}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"reduce": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument 1 to `reduce` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			elements := args[0].(*object.Array).Elements
+			start := 0
+			var acc object.Object
+			if len(args) == 3 {
+				acc = args[2]
+			} else {
+				if len(elements) == 0 {
+					return newError("`reduce` of empty ARRAY with no initial value")
+				}
+				acc = elements[0]
+				start = 1
+			}
+
+			for i := start; i < len(elements); i++ {
+				acc = callWithIndex(ctx, args[1], i, acc, elements[i])
+				if isError(acc) {
+					return acc
+				}
+			}
+			return acc
+		},
+	},
+	"sort": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument 1 to `sort` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			if err := ctx.CheckAllocation(int64(len(args[0].(*object.Array).Elements))); err != nil {
+				return err
+			}
+
+			elements := copyElements(args[0].(*object.Array))
+			var err object.Object
+			var comparisons int64
+			sort.SliceStable(elements, func(i, j int) bool {
+				if err != nil {
+					return false
+				}
+				if cancelled := tick(ctx, comparisons); cancelled != nil {
+					err = cancelled
+					return false
+				}
+				comparisons++
+				var less bool
+				if len(args) == 2 {
+					less, err = callComparator(ctx, args[1], elements[i], elements[j])
+				} else {
+					var c int
+					c, err = compareObjects(elements[i], elements[j])
+					less = c < 0
+				}
+				return less
+			})
+			if err != nil {
+				return err
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"sortBy": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument 1 to `sortBy` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			type keyed struct {
+				key, value object.Object
+			}
+
+			arr := args[0].(*object.Array)
+			if err := ctx.CheckAllocation(int64(len(arr.Elements))); err != nil {
+				return err
+			}
+			pairs := make([]keyed, len(arr.Elements))
+			for i, el := range arr.Elements {
+				key := ctx.Call(args[1], el)
+				if isError(key) {
+					return key
+				}
+				pairs[i] = keyed{key: key, value: el}
+			}
+
+			var err object.Object
+			sort.SliceStable(pairs, func(i, j int) bool {
+				if err != nil {
+					return false
+				}
+				var c int
+				c, err = compareObjects(pairs[i].key, pairs[j].key)
+				return c < 0
+			})
+			if err != nil {
+				return err
+			}
+
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.value
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"find": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument 1 to `find` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			for i, el := range args[0].(*object.Array).Elements {
+				result := callWithIndex(ctx, args[1], i, el)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return el
+				}
+			}
+			return NULL
+		},
+	},
+	"findIndex": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument 1 to `findIndex` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			for i, el := range args[0].(*object.Array).Elements {
+				result := callWithIndex(ctx, args[1], i, el)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return &object.Integer{Value: int64(i)}
+				}
+			}
+			return &object.Integer{Value: -1}
+		},
+	},
+	"any": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument 1 to `any` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			for i, el := range args[0].(*object.Array).Elements {
+				result := callWithIndex(ctx, args[1], i, el)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return TRUE
+				}
+			}
+			return FALSE
+		},
+	},
+	"all": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument 1 to `all` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			for i, el := range args[0].(*object.Array).Elements {
+				result := callWithIndex(ctx, args[1], i, el)
+				if isError(result) {
+					return result
+				}
+				if !isTruthy(result) {
+					return FALSE
+				}
+			}
+			return TRUE
+		},
+	},
+	"zip": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1",
+					len(args))
+			}
+
+			length := -1
+			for i, arg := range args {
+				arr, ok := arg.(*object.Array)
+				if !ok {
+					return newError("argument %d to `zip` must be ARRAY, got %s",
+						i+1, arg.Type())
+				}
+				if length < 0 || len(arr.Elements) < length {
+					length = len(arr.Elements)
+				}
+			}
+
+			elements := make([]object.Object, length)
+			for i := range elements {
+				tuple := make([]object.Object, len(args))
+				for j, arg := range args {
+					tuple[j] = arg.(*object.Array).Elements[i]
+				}
+				elements[i] = &object.Array{Elements: tuple}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"enumerate": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `enumerate` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			elements := make([]object.Object, len(arr.Elements))
+			for i, el := range arr.Elements {
+				elements[i] = &object.Array{
+					Elements: []object.Object{&object.Integer{Value: int64(i)}, el},
+				}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"flatten": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument 1 to `flatten` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			depth := int64(1)
+			if len(args) == 2 {
+				d, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("argument 2 to `flatten` must be INTEGER, got %s",
+						args[1].Type())
+				}
+				depth = d.Value
+			}
+
+			return &object.Array{Elements: flattenElements(args[0].(*object.Array), depth)}
+		},
+	},
+	"reverse": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `reverse` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+			elements := make([]object.Object, length)
+			for i, el := range arr.Elements {
+				elements[length-1-i] = el
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"join": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument 1 to `join` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			sep := ""
+			if len(args) == 2 {
+				s, ok := args[1].(*object.String)
+				if !ok {
+					return newError("argument 2 to `join` must be STRING, got %s",
+						args[1].Type())
+				}
+				sep = s.Value
+			}
+
+			arr := args[0].(*object.Array)
+			parts := make([]string, len(arr.Elements))
+			for i, el := range arr.Elements {
+				parts[i] = el.Inspect()
+			}
+			return &object.String{Value: strings.Join(parts, sep)}
+		},
+	},
+	"sum": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `sum` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			var total object.Object = &object.Integer{Value: 0}
+			for _, el := range args[0].(*object.Array).Elements {
+				if el.Type() != object.INTEGER_OBJ && el.Type() != object.FLOAT_OBJ {
+					return newError("`sum` expects INTEGER or FLOAT elements, got %s",
+						el.Type())
+				}
+				total = evalInfixExpression("+", total, el)
+			}
+			return total
+		},
+	},
+	"range": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=1 to 3",
+					len(args))
+			}
+
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				n, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("argument %d to `range` must be INTEGER, got %s",
+						i+1, arg.Type())
+				}
+				bounds[i] = n.Value
+			}
+
+			start, end, step := int64(0), bounds[0], int64(1)
+			if len(bounds) > 1 {
+				start, end = bounds[0], bounds[1]
+			}
+			if len(bounds) > 2 {
+				step = bounds[2]
+			}
+			if step == 0 {
+				return newError("`range` step must not be 0")
+			}
+
+			length := rangeLength(start, end, step)
+			if err := ctx.CheckAllocation(length); err != nil {
+				return err
+			}
+
+			elements := []object.Object{}
+			for i := int64(0); i < length; i++ {
+				if err := tick(ctx, i); err != nil {
+					return err
+				}
+				elements = append(elements, &object.Integer{Value: start + i*step})
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"indexOf": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				for i, el := range arg.Elements {
+					if object.Equals(el, args[1]) {
+						return &object.Integer{Value: int64(i)}
+					}
+				}
+				return &object.Integer{Value: -1}
+			case *object.String:
+				substr, err := stringArg("indexOf", args, 1)
+				if err != nil {
+					return err
+				}
+				// Like chars and len, count runes rather than bytes
+				i := strings.Index(arg.Value, substr)
+				if i > 0 {
+					i = utf8.RuneCountInString(arg.Value[:i])
+				}
+				return &object.Integer{Value: int64(i)}
+			default:
+				return newError("argument 1 to `indexOf` not supported, got %s",
+					args[0].Type())
+			}
+		},
+	},
+	"uniq": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `uniq` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			seen := object.NewHash()
+			elements := []object.Object{}
+			for _, el := range args[0].(*object.Array).Elements {
+				if _, ok := object.HashKeyOf(el); ok {
+					if _, dup := seen.Get(el); dup {
+						continue
+					}
+					seen.Set(el, TRUE)
+				} else if containsObject(elements, el) {
+					continue
+				}
+				elements = append(elements, el)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"chunk": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument 1 to `chunk` must be ARRAY, got %s",
+					args[0].Type())
+			}
+			size, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("argument 2 to `chunk` must be INTEGER, got %s",
+					args[1].Type())
+			}
+			if size.Value <= 0 {
+				return newError("`chunk` size must be positive, got %d", size.Value)
+			}
+
+			arr := args[0].(*object.Array)
+			elements := []object.Object{}
+			for i := 0; i < len(arr.Elements); i += int(size.Value) {
+				end := min(int64(i)+size.Value, int64(len(arr.Elements)))
+				part := make([]object.Object, end-int64(i))
+				copy(part, arr.Elements[i:end])
+				elements = append(elements, &object.Array{Elements: part})
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"str": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			return &object.String{Value: args[0].Inspect()}
+		},
+	},
+	"upper": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			s, err := stringArg("upper", args, 0)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToUpper(s)}
+		},
+	},
+	"lower": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			s, err := stringArg("lower", args, 0)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToLower(s)}
+		},
+	},
+	"replace": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 3 && len(args) != 4 {
+				return newError("wrong number of arguments. got=%d, want=3 or 4",
+					len(args))
+			}
+			strs, err := stringArgs("replace", args[:3])
+			if err != nil {
+				return err
+			}
+			n := int64(-1)
+			if len(args) == 4 {
+				n, err = integerArg("replace", args, 3)
+				if err != nil {
+					return err
+				}
+			}
+			return &object.String{Value: strings.Replace(strs[0], strs[1], strs[2], int(n))}
+		},
+	},
+	"startsWith": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			strs, err := stringArgs("startsWith", args)
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.HasPrefix(strs[0], strs[1]))
+		},
+	},
+	"endsWith": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			strs, err := stringArgs("endsWith", args)
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.HasSuffix(strs[0], strs[1]))
+		},
+	},
+	"repeat": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			s, err := stringArg("repeat", args, 0)
+			if err != nil {
+				return err
+			}
+			n, err := integerArg("repeat", args, 1)
+			if err != nil {
+				return err
+			}
+			if n < 0 {
+				return newError("argument 2 to `repeat` must not be negative, got %d", n)
+			}
+			if n > 0 && int64(len(s)) > maxStringLength/n {
+				return newError("result of `repeat` would exceed %d bytes", maxStringLength)
+			}
+			if err := ctx.CheckAllocation(int64(len(s)) * n); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.Repeat(s, int(n))}
+		},
+	},
+	"padLeft": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			return padString(ctx, "padLeft", args, true)
+		},
+	},
+	"padRight": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			return padString(ctx, "padRight", args, false)
+		},
+	},
+	"chars": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			s, err := stringArg("chars", args, 0)
+			if err != nil {
+				return err
+			}
+
+			elements := []object.Object{}
+			for _, r := range s {
+				elements = append(elements, &object.Char{Value: r})
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"splitLines": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			s, err := stringArg("splitLines", args, 0)
+			if err != nil {
+				return err
+			}
+
+			s = strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+			if s == "" {
+				return &object.Array{Elements: []object.Object{}}
+			}
+			return stringsToArray(strings.Split(s, "\n"))
+		},
+	},
+	"splitWhitespace": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			s, err := stringArg("splitWhitespace", args, 0)
+			if err != nil {
+				return err
+			}
+			return stringsToArray(strings.Fields(s))
+		},
+	},
+	"ord": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if c, ok := args[0].(*object.Char); ok {
+				return &object.Integer{Value: int64(c.Value)}
+			}
+			s, err := stringArg("ord", args, 0)
+			if err != nil {
+				return err
+			}
+			if utf8.RuneCountInString(s) != 1 {
+				return newError("argument to `ord` must be a single character, got %q", s)
+			}
+			r, _ := utf8.DecodeRuneInString(s)
+			return &object.Integer{Value: int64(r)}
+		},
+	},
+	"chr": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			n, err := integerArg("chr", args, 0)
+			if err != nil {
+				return err
+			}
+			if n < 0 || n > unicode.MaxRune {
+				return newError("argument to `chr` out of range, got %d", n)
+			}
+			return &object.Char{Value: rune(n)}
+		},
+	},
+	"isDigit": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			s, err := textArg("isDigit", args, 0)
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(s != "" && strings.IndexFunc(s, func(r rune) bool {
+				return !unicode.IsDigit(r)
+			}) < 0)
+		},
+	},
+	"isAlpha": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			s, err := textArg("isAlpha", args, 0)
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(s != "" && strings.IndexFunc(s, func(r rune) bool {
+				return !unicode.IsLetter(r)
+			}) < 0)
+		},
+	},
+	"format": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			return formatString("format", args)
+		},
+	},
+	"sprintf": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			return formatString("sprintf", args)
+		},
+	},
+	"regex": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			re, err := regexArg("regex", args, 0)
+			if err != nil {
+				return err
+			}
+			return &object.Regex{Value: re}
+		},
+	},
+	"match": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			re, err := regexArg("match", args, 0)
+			if err != nil {
+				return err
+			}
+			s, err := stringArg("match", args, 1)
+			if err != nil {
+				return err
+			}
+
+			loc := re.FindStringSubmatchIndex(s)
+			if loc == nil {
+				return NULL
+			}
+			return regexGroups(re, s, loc)
+		},
+	},
+	"matchAll": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			re, err := regexArg("matchAll", args, 0)
+			if err != nil {
+				return err
+			}
+			s, err := stringArg("matchAll", args, 1)
+			if err != nil {
+				return err
+			}
+
+			elements := []object.Object{}
+			for _, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+				elements = append(elements, regexGroups(re, s, loc))
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"findAll": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			re, err := regexArg("findAll", args, 0)
+			if err != nil {
+				return err
+			}
+			s, err := stringArg("findAll", args, 1)
+			if err != nil {
+				return err
+			}
+
+			matches := re.FindAllString(s, -1)
+			if matches == nil {
+				matches = []string{}
+			}
+			return stringsToArray(matches)
+		},
+	},
+	"replaceRegex": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3",
+					len(args))
+			}
+			re, err := regexArg("replaceRegex", args, 0)
+			if err != nil {
+				return err
+			}
+			s, err := stringArg("replaceRegex", args, 1)
+			if err != nil {
+				return err
+			}
+
+			switch repl := args[2].(type) {
+			case *object.String:
+				return &object.String{Value: re.ReplaceAllString(s, repl.Value)}
+			case *object.Function, *object.Builtin:
+				// The callback gets the same groups match returns, and the
+				// match is replaced with whatever it returns, as by str.
+				var out strings.Builder
+				last := 0
+				for _, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+					result := ctx.Call(repl, regexGroups(re, s, loc))
+					if isError(result) {
+						return result
+					}
+					if result == nil {
+						result = NULL
+					}
+					out.WriteString(s[last:loc[0]])
+					out.WriteString(result.Inspect())
+					last = loc[1]
+				}
+				out.WriteString(s[last:])
+				return &object.String{Value: out.String()}
+			default:
+				return newError("argument 3 to `replaceRegex` must be STRING or FUNCTION, got %s",
+					args[2].Type())
+			}
+		},
+	},
+	"splitRegex": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			re, err := regexArg("splitRegex", args, 0)
+			if err != nil {
+				return err
+			}
+			s, err := stringArg("splitRegex", args, 1)
+			if err != nil {
+				return err
+			}
+			return stringsToArray(re.Split(s, -1))
+		},
+	},
+	"puts": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprintln(ctx.Streams().Stdout, arg.Inspect())
+			}
+
+			return NULL
+		},
+	},
+	"print": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			writeObjects(ctx.Streams().Stdout, args)
+			return NULL
+		},
+	},
+	"eprint": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			writeObjects(ctx.Streams().Stderr, args)
+			return NULL
+		},
+	},
+	"printf": {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			formatted := formatString("printf", args)
+			if isError(formatted) {
+				return formatted
+			}
+
+			io.WriteString(ctx.Streams().Stdout, formatted.(*object.String).Value)
+			return NULL
+		},
+	},
+}
+
+// writeObjects writes args to w separated by spaces, without a newline.
+func writeObjects(w io.Writer, args []object.Object) {
+	for i, arg := range args {
+		if i > 0 {
+			io.WriteString(w, " ")
+		}
+		io.WriteString(w, arg.Inspect())
+	}
+}
+
+func min(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// callWithIndex calls a callback with args, followed by index when fn is a
+// Nut function that declares a parameter for it.
+func callWithIndex(ctx object.Context, fn object.Object, index int, args ...object.Object) object.Object {
+	if f, ok := fn.(*object.Function); ok && len(f.Parameters) > len(args) {
+		args = append(args, &object.Integer{Value: int64(index)})
+	}
+	return ctx.Call(fn, args...)
+}
+
+func copyElements(arr *object.Array) []object.Object {
+	elements := make([]object.Object, len(arr.Elements))
+	copy(elements, arr.Elements)
+	return elements
+}
+
+func containsObject(elements []object.Object, obj object.Object) bool {
+	for _, el := range elements {
+		if object.Equals(el, obj) {
+			return true
+		}
+	}
+	return false
+}
+
+func flattenElements(arr *object.Array, depth int64) []object.Object {
+	elements := []object.Object{}
+	for _, el := range arr.Elements {
+		if inner, ok := el.(*object.Array); ok && depth > 0 {
+			elements = append(elements, flattenElements(inner, depth-1)...)
+		} else {
+			elements = append(elements, el)
+		}
+	}
+	return elements
+}
+
+// compareObjects orders numbers by value and strings and arrays
+// lexicographically. Other values, or values of different kinds, can not
+// be ordered and produce an error.
+func compareObjects(a, b object.Object) (int, object.Object) {
+	switch {
+	case isNumber(a) && isNumber(b):
+		if a.Type() == object.INTEGER_OBJ && b.Type() == object.INTEGER_OBJ {
+			return cmp.Compare(a.(*object.Integer).Value, b.(*object.Integer).Value), nil
+		}
+		return cmp.Compare(toFloat(a), toFloat(b)), nil
+
+	case a.Type() == object.STRING_OBJ && b.Type() == object.STRING_OBJ:
+		return strings.Compare(a.(*object.String).Value, b.(*object.String).Value), nil
+
+	case a.Type() == object.ARRAY_OBJ && b.Type() == object.ARRAY_OBJ:
+		left := a.(*object.Array).Elements
+		right := b.(*object.Array).Elements
+		for i := 0; i < len(left) && i < len(right); i++ {
+			c, err := compareObjects(left[i], right[i])
+			if err != nil || c != 0 {
+				return c, err
+			}
+		}
+		return cmp.Compare(len(left), len(right)), nil
+
+	default:
+		return 0, newError("cannot compare %s with %s", a.Type(), b.Type())
+	}
+}
+
+// callComparator asks a user comparator whether a sorts before b. The
+// comparator may answer with a BOOLEAN ("a is less than b") or with an
+// INTEGER that is negative when a sorts first.
+func callComparator(ctx object.Context, fn, a, b object.Object) (bool, object.Object) {
+	result := ctx.Call(fn, a, b)
+
+	switch result := result.(type) {
+	case *object.Error:
+		return false, result
+	case *object.Boolean:
+		return result.Value, nil
+	case *object.Integer:
+		return result.Value < 0, nil
+	default:
+		return false, newError("comparator must return BOOLEAN or INTEGER, got %s",
+			result.Type())
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
+// argumentName is how type errors refer to argument i of a builtin: just
+// "argument" when it takes a single one, "argument 2" and so on otherwise.
+func argumentName(args []object.Object, i int) string {
+	if len(args) == 1 {
+		return "argument"
+	}
+	return fmt.Sprintf("argument %d", i+1)
+}
+
+func stringArg(name string, args []object.Object, i int) (string, *object.Error) {
+	s, ok := args[i].(*object.String)
+	if !ok {
+		return "", newError("%s to `%s` must be STRING, got %s",
+			argumentName(args, i), name, args[i].Type())
+	}
+	return s.Value, nil
+}
+
+// textArg is like stringArg but also accepts a CHAR.
+func textArg(name string, args []object.Object, i int) (string, *object.Error) {
+	switch arg := args[i].(type) {
+	case *object.String:
+		return arg.Value, nil
+	case *object.Char:
+		return string(arg.Value), nil
+	default:
+		return "", newError("%s to `%s` must be STRING or CHAR, got %s",
+			argumentName(args, i), name, args[i].Type())
+	}
+}
+
+func stringArgs(name string, args []object.Object) ([]string, *object.Error) {
+	strs := make([]string, len(args))
+	for i := range args {
+		s, err := stringArg(name, args, i)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
+
+func integerArg(name string, args []object.Object, i int) (int64, *object.Error) {
+	n, ok := args[i].(*object.Integer)
+	if !ok {
+		return 0, newError("%s to `%s` must be INTEGER, got %s",
+			argumentName(args, i), name, args[i].Type())
+	}
+	return n.Value, nil
+}
+
+func stringsToArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &object.String{Value: s}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// rangeLength returns the number of integers range yields from start to
+// end by step, without overflowing.
+func rangeLength(start, end, step int64) int64 {
+	var length uint64
+	switch {
+	case step > 0 && start < end:
+		length = (uint64(end)-uint64(start)-1)/uint64(step) + 1
+	case step < 0 && start > end:
+		length = (uint64(start)-uint64(end)-1)/-uint64(step) + 1
+	}
+	if length > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(length)
+}
+
+// maxStringLength bounds the strings built by builtins whose size comes
+// from an argument, such as repeat and padLeft.
+const maxStringLength = 1 << 30
+
+// padString pads args[0] to the width args[1] with args[2], or spaces,
+// counting characters rather than bytes.
+func padString(ctx object.Context, name string, args []object.Object, left bool) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3",
+			len(args))
+	}
+	s, err := stringArg(name, args, 0)
+	if err != nil {
+		return err
+	}
+	width, err := integerArg(name, args, 1)
+	if err != nil {
+		return err
+	}
+	pad := " "
+	if len(args) == 3 {
+		pad, err = stringArg(name, args, 2)
+		if err != nil {
+			return err
+		}
+		if pad == "" {
+			return newError("argument 3 to `%s` must not be empty", name)
+		}
+	}
+
+	missing := width - int64(utf8.RuneCountInString(s))
+	if missing <= 0 {
+		return &object.String{Value: s}
+	}
+
+	// The pad is repeated whole and then cut, so its size is in bytes
+	padRunes := int64(utf8.RuneCountInString(pad))
+	count := (missing + padRunes - 1) / padRunes
+	if count > (maxStringLength-int64(len(s)))/int64(len(pad)) {
+		return newError("result of `%s` would exceed %d bytes", name, maxStringLength)
+	}
+	if err := ctx.CheckAllocation(int64(len(s)) + count*int64(len(pad))); err != nil {
+		return err
+	}
+
+	padding := strings.Repeat(pad, int(count))
+	for i := range padding {
+		if missing == 0 {
+			padding = padding[:i]
+			break
+		}
+		missing--
+	}
+	if left {
+		return &object.String{Value: padding + s}
+	}
+	return &object.String{Value: s + padding}
+}
+
+// formatString implements format and sprintf. It understands the usual
+// fmt flags, width and precision, with verbs that take Nut values:
+//
+//	%s, %v  any value, as printed by puts
+//	%q      any value, quoted
+//	%d, %x  INTEGER
+//	%f, %e, %g  INTEGER or FLOAT
+//	%t      BOOLEAN
+//	%%      a literal percent sign
+func formatString(name string, args []object.Object) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got=%d, want at least 1",
+			len(args))
+	}
+	format, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument 1 to `%s` must be STRING, got %s",
+			name, args[0].Type())
+	}
+
+	var out strings.Builder
+	values := args[1:]
+	next := 0
+
+	s := format.Value
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			out.WriteByte(s[i])
+			continue
+		}
+
+		// Collect flags, width and precision up to the verb.
+		start := i
+		i++
+		for i < len(s) && strings.IndexByte("+-# 0123456789.", s[i]) >= 0 {
+			i++
+		}
+		if i >= len(s) {
+			return newError("`%s`: format ends in the middle of a verb", name)
+		}
+
+		verb := s[i]
+		spec := s[start : i+1]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+
+		if next >= len(values) {
+			return newError("`%s`: missing value for %s", name, spec)
+		}
+		value := values[next]
+		next++
+
+		var arg interface{}
+		switch verb {
+		case 's', 'v':
+			arg = value.Inspect()
+			spec = spec[:len(spec)-1] + "s"
+		case 'q':
+			arg = value.Inspect()
+		case 'd', 'x', 'X', 'b', 'o':
+			n, ok := value.(*object.Integer)
+			if !ok {
+				return newError("`%s`: %s wants INTEGER, got %s", name, spec, value.Type())
+			}
+			arg = n.Value
+		case 'f', 'F', 'e', 'E', 'g', 'G':
+			if !isNumber(value) {
+				return newError("`%s`: %s wants INTEGER or FLOAT, got %s", name, spec, value.Type())
+			}
+			arg = toFloat(value)
+		case 't':
+			b, ok := value.(*object.Boolean)
+			if !ok {
+				return newError("`%s`: %s wants BOOLEAN, got %s", name, spec, value.Type())
+			}
+			arg = b.Value
+		default:
+			return newError("`%s`: unknown verb %s", name, spec)
+		}
+
+		fmt.Fprintf(&out, spec, arg)
+	}
+
+	if next < len(values) {
+		return newError("`%s`: %d unused values", name, len(values)-next)
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+// regexArg accepts either a REGEX or a STRING holding a pattern.
+func regexArg(name string, args []object.Object, i int) (*regexp.Regexp, *object.Error) {
+	switch arg := args[i].(type) {
+	case *object.Regex:
+		return arg.Value, nil
+	case *object.String:
+		re, err := regexp.Compile(arg.Value)
+		if err != nil {
+			return nil, newError("invalid regular expression %q: %s", arg.Value, err)
+		}
+		return re, nil
+	default:
+		return nil, newError("%s to `%s` must be REGEX or STRING, got %s",
+			argumentName(args, i), name, args[i].Type())
+	}
+}
+
+// regexGroups turns a submatch index slice into a Nut value: an array of
+// the whole match followed by each group, or, when the expression has
+// named groups, a hash from group name (or number, for unnamed groups) to
+// text. Groups that did not take part in the match are null.
+func regexGroups(re *regexp.Regexp, s string, loc []int) object.Object {
+	groups := make([]object.Object, len(loc)/2)
+	for i := range groups {
+		if loc[2*i] < 0 {
+			groups[i] = NULL
+		} else {
+			groups[i] = &object.String{Value: s[loc[2*i]:loc[2*i+1]]}
+		}
+	}
+
+	names := re.SubexpNames()
+	named := false
+	for _, name := range names {
+		named = named || name != ""
+	}
+	if !named {
+		return &object.Array{Elements: groups}
+	}
+
+	hash := object.NewHash()
+	for i, group := range groups {
+		if names[i] != "" {
+			hash.Set(&object.String{Value: names[i]}, group)
+		} else {
+			hash.Set(&object.Integer{Value: int64(i)}, group)
+		}
+	}
+	return hash
 }
@@ -3,7 +3,10 @@ package evaluator
 import (
 	"Nutlang/ast"
 	"Nutlang/object"
+	"Nutlang/token"
 	"fmt"
+	"math"
+	"strings"
 )
 
 var (
@@ -13,6 +16,20 @@ var (
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if err := step(env); err != nil {
+		return err
+	}
+
+	result := eval(node, env)
+
+	// The innermost node an error comes from is where it was raised
+	if err, ok := result.(*object.Error); ok && err.Line == 0 {
+		locateError(err, node, env)
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	// Statements
@@ -20,88 +37,84 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalProgram(node.Statements, env)
 
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return allocated(env, evalHashLiteral(node, env))
 
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Name: node.Name, Parameters: params, Body: body, Env: env}
+
+	case *ast.FunctionStatement:
+		// Defined when entering the enclosing program or block
+		return nil
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-		return applyFunction(function, args)
+		return evalCallExpression(node, env)
 
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+
+	case *ast.InterpolatedString:
+		return allocated(env, evalInterpolatedString(node, env))
+
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		if isAbrupt(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
-	case *ast.AssignmentExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
-			return left
-		}
+	case *ast.ThrowStatement:
 		val := Eval(node.Value, env)
-		if isError(val) {
+		if isAbrupt(val) {
 			return val
 		}
-		if ident, ok := node.Left.(*ast.Identifier); ok {
-			env.Set(ident.Value, val)
-		} else if ie, ok := node.Left.(*ast.IndexExpression); ok {
-			obj := Eval(ie.Left, env)
-			if isError(obj) {
-				return obj
-			}
+		return throwValue(val)
 
-			if array, ok := obj.(*object.Array); ok {
-				index := Eval(ie.Index, env)
-				if isError(index) {
-					return index
-				}
-				if idx, ok := index.(*object.Integer); ok {
-					array.Elements[idx.Value] = val
-				} else {
-					return newError("cannot index array with %#v", index)
-				}
-			} else if hash, ok := obj.(*object.Hash); ok {
-				key := Eval(ie.Index, env)
-				if isError(key) {
-					return key
-				}
-				if hashKey, ok := key.(object.Hashable); ok {
-					hashed := hashKey.HashKey()
-					hash.Pairs[hashed] = object.HashPair{Key: key, Value: val}
-				} else {
-					return newError("cannot index hash with %T", key)
-				}
-			} else {
-				return newError("object type %T does not support item assignment", obj)
-			}
-		} else {
-			return newError("expected identifier or index expression got=%T", left)
-		}
-		return val
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+
+	case *ast.PostfixExpression:
+		return evalPropagateExpression(node, env)
+
+	case *ast.AssignmentExpression:
+		return evalAssignmentExpression(node, env)
+
+	case *ast.IncrementExpression:
+		return evalIncrementExpression(node, env)
+
+	case *ast.SpreadElement:
+		return newError("unexpected ... outside of a destructuring pattern")
 
 	case *ast.LetStatement:
+		if node.Pattern != nil {
+			val := Eval(node.Value, env)
+			if isAbrupt(val) {
+				return val
+			}
+			if err := destructure(node.Pattern, val, env, declare(env, node.Token.Type == token.CONST)); err != nil {
+				return err
+			}
+			return nil
+		}
+		if env.IsLocalConst(node.Name.Value) {
+			return newError("cannot redeclare constant %s", node.Name.Value)
+		}
 		val := Eval(node.Value, env)
-		if isError(val) {
+		if isAbrupt(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.Token.Type == token.CONST {
+			env.SetConst(node.Name.Value, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
 
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
@@ -112,26 +125,32 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FloatLiteral:
 		return &object.Float{Value: node.Value}
 
+	case *ast.RegexLiteral:
+		return &object.Regex{Value: node.Value}
+
+	case *ast.CharLiteral:
+		return &object.Char{Value: node.Value}
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isAbrupt(left) {
 			return left
 		}
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return allocated(env, evalInfixExpression(node.Operator, left, right))
 
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
@@ -139,6 +158,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 
+	case *ast.ConditionalExpression:
+		return evalConditionalExpression(node, env)
+
 	case *ast.ForWhileExpression:
 		return evalForWhileExpression(node, env)
 
@@ -147,18 +169,18 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		if len(elements) == 1 && isAbrupt(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return allocated(env, &object.Array{Elements: elements})
 
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isAbrupt(left) {
 			return left
 		}
 		index := Eval(node.Index, env)
-		if isError(index) {
+		if isAbrupt(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
@@ -167,24 +189,49 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	return nil
 }
 
+func evalCallExpression(node *ast.CallExpression, env *object.Environment) object.Object {
+	function := Eval(node.Function, env)
+	if isAbrupt(function) {
+		return function
+	}
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isAbrupt(args[0]) {
+		return args[0]
+	}
+
+	// Record the call for the stack of errors raised within it
+	rt := env.Runtime()
+	rt.Frames = append(rt.Frames, callFrame(node, function))
+	defer func() { rt.Frames = rt.Frames[:len(rt.Frames)-1] }()
+
+	return applyFunction(function, args, env)
+}
+
 func evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
 	var result object.Object
 
 	// First part of a for should run once
-	Eval(fe.Statement, env)
+	if init := Eval(fe.Statement, env); isAbrupt(init) {
+		return init
+	}
 
 	for {
 		header := Eval(fe.Condition, env)
-		if isError(header) {
+		if isAbrupt(header) {
 			return header
 		}
 
 		if isTruthy(header) {
 			// Evaluate body (again)
 			result = Eval(fe.Body, env)
+			if isAbrupt(result) {
+				return result
+			}
 
 			// Is this necessary?
-			Eval(fe.Expression, env)
+			if post := Eval(fe.Expression, env); isAbrupt(post) {
+				return post
+			}
 		} else {
 			break
 		}
@@ -204,12 +251,15 @@ func evalForWhileExpression(
 
 	for {
 		header := Eval(few.Condition, env)
-		if isError(header) {
+		if isAbrupt(header) {
 			return header
 		}
 
 		if isTruthy(header) {
 			result = Eval(few.Body, env)
+			if isAbrupt(result) {
+				return result
+			}
 		} else {
 			break
 		}
@@ -225,29 +275,48 @@ func evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
 		key := Eval(keyNode, env)
-		if isError(key) {
+		if isAbrupt(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
+		if _, ok := object.HashKeyOf(key); !ok {
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
-		if isError(value) {
+		value := Eval(node.Pairs[keyNode], env)
+		if isAbrupt(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(key, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
+}
+
+func evalInterpolatedString(
+	node *ast.InterpolatedString,
+	env *object.Environment,
+) object.Object {
+	var out strings.Builder
+
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isAbrupt(value) {
+			return value
+		}
+		if value == nil {
+			// Like an empty block, ${if (true) {}} has no value
+			value = NULL
+		}
+		out.WriteString(value.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
 }
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
@@ -268,54 +337,125 @@ func evalIndexExpression(left, index object.Object) object.Object {
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.EXCEPTION_OBJ && index.Type() == object.STRING_OBJ:
+		return evalExceptionIndexExpression(left, index)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
+// evalStringIndexExpression returns the character at position index,
+// counting characters like len, chars and indexOf.
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	value := str.(*object.String).Value
+	idx := index.(*object.Integer).Value
+
+	if idx >= 0 {
+		i := int64(0)
+		for _, r := range value {
+			if i == idx {
+				return &object.Char{Value: r}
+			}
+			i++
+		}
+	}
+	return NULL
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
-	if !ok {
+	if _, ok := object.HashKeyOf(index); !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(index)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
+}
+
+// Call applies fn, a Nut function or builtin, to args as if called from
+// code running in env.
+func Call(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	return applyFunction(fn, args, env)
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// applyFunction calls fn with args on behalf of code running in env.
+func applyFunction(
+	fn object.Object,
+	args []object.Object,
+	env *object.Environment,
+) object.Object {
 	switch fn := fn.(type) {
 
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if len(args) < len(fn.Parameters) {
+			return newError("wrong number of arguments. got=%d, want=%d",
+				len(args), len(fn.Parameters))
+		}
+		if err := enterCall(env); err != nil {
+			return err
+		}
+		defer leaveCall(env)
+
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		evaluated := unwrapReturnValue(Eval(fn.Body, extendedEnv))
+		if evaluated == nil {
+			// Functions with an empty body return null
+			return NULL
+		}
+		return evaluated
 
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return allocated(env, fn.Fn(evalContext{env: env}, args...))
 
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// evalContext is the object.Context handed to builtins.
+type evalContext struct {
+	env *object.Environment
+}
+
+func (c evalContext) Call(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args, c.env)
+}
+
+func (c evalContext) Streams() *object.Streams {
+	return c.env.Streams()
+}
+
+func (c evalContext) Runtime() *object.Runtime {
+	return c.env.Runtime()
+}
+
+func (c evalContext) CheckAllocation(size int64) *object.Error {
+	return checkAllocation(c.env.Runtime(), size)
+}
+
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
-) *object.Environment {
+) (*object.Environment, object.Object) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if err := destructure(param, args[paramIdx], env, declare(env, false)); err != nil {
+			return nil, err
+		}
 	}
 
-	return env
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -331,7 +471,7 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 
 	for _, e := range exps {
 		evaluated := Eval(e, env)
-		if isError(evaluated) {
+		if isAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 		results = append(results, evaluated)
@@ -348,12 +488,16 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newKindError(object.NAME_ERROR, "identifier not found: "+node.Value)
 }
 
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
+	if err := hoistFunctions(block.Statements, env); err != nil {
+		return err
+	}
+
 	for _, statement := range block.Statements {
 		result = Eval(statement, env)
 
@@ -370,6 +514,10 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
+	if err := hoistFunctions(stmts, env); err != nil {
+		return err
+	}
+
 	for _, statement := range stmts {
 		result = Eval(statement, env)
 
@@ -383,6 +531,26 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	return result
 }
 
+// hoistFunctions defines the functions declared by stmts, so that they
+// can be called before their declaration and call each other.
+func hoistFunctions(stmts []ast.Statement, env *object.Environment) *object.Error {
+	for _, statement := range stmts {
+		fs, ok := statement.(*ast.FunctionStatement)
+		if !ok {
+			continue
+		}
+		if env.IsLocalConst(fs.Name.Value) {
+			return newError("cannot redeclare constant %s", fs.Name.Value)
+		}
+		fn := Eval(fs.Function, env)
+		if err, ok := fn.(*object.Error); ok {
+			return err
+		}
+		env.Set(fs.Name.Value, fn)
+	}
+	return nil
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -437,6 +605,13 @@ func evalInfixExpression(
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+
+		// Equality is structural and defined between any two values.
+	case operator == "==":
+		return nativeBoolToBooleanObject(object.Equals(left, right))
+	case operator == "!=":
+		return nativeBoolToBooleanObject(!object.Equals(left, right))
+
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ:
@@ -445,6 +620,8 @@ func evalInfixExpression(
 		return evalFloatIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.FLOAT_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalFloatIntegerInfixExpression(operator, left, right)
+	case left.Type() == object.CHAR_OBJ || right.Type() == object.CHAR_OBJ:
+		return evalCharInfixExpression(operator, left, right)
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -454,12 +631,6 @@ func evalInfixExpression(
 	case operator == "||":
 		return evalBooleanInfixExpression(operator, left, right)
 
-		// Pointer comparison, should be last.
-	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
-	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
-
 	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -495,6 +666,59 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	return &object.String{Value: leftVal + rightVal}
 }
 
+// evalCharInfixExpression handles every infix expression involving a
+// CHAR: comparing characters, the distance between two characters,
+// offsetting a character by an INTEGER and concatenation with a STRING.
+func evalCharInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	switch {
+	case left.Type() == object.CHAR_OBJ && right.Type() == object.CHAR_OBJ:
+		leftVal := left.(*object.Char).Value
+		rightVal := right.(*object.Char).Value
+
+		switch operator {
+		case "-":
+			return &object.Integer{Value: int64(leftVal - rightVal)}
+		case "<":
+			return nativeBoolToBooleanObject(leftVal < rightVal)
+		case ">":
+			return nativeBoolToBooleanObject(leftVal > rightVal)
+		case "<=":
+			return nativeBoolToBooleanObject(leftVal <= rightVal)
+		case ">=":
+			return nativeBoolToBooleanObject(leftVal >= rightVal)
+		}
+
+	case left.Type() == object.CHAR_OBJ && right.Type() == object.INTEGER_OBJ:
+		leftVal := int64(left.(*object.Char).Value)
+		rightVal := right.(*object.Integer).Value
+
+		switch operator {
+		case "+":
+			return &object.Char{Value: rune(leftVal + rightVal)}
+		case "-":
+			return &object.Char{Value: rune(leftVal - rightVal)}
+		}
+
+	case left.Type() == object.INTEGER_OBJ && operator == "+":
+		value := left.(*object.Integer).Value + int64(right.(*object.Char).Value)
+		return &object.Char{Value: rune(value)}
+
+	case operator == "+" &&
+		(left.Type() == object.STRING_OBJ || right.Type() == object.STRING_OBJ):
+		return &object.String{Value: left.Inspect() + right.Inspect()}
+
+	case left.Type() != object.INTEGER_OBJ && right.Type() != object.INTEGER_OBJ:
+		return newError("type mismatch: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+
+	return newError("unknown operator: %s %s %s",
+		left.Type(), operator, right.Type())
+}
+
 func evalFloatIntegerInfixExpression(
 	operator string,
 	left, right object.Object,
@@ -522,6 +746,8 @@ func evalFloatIntegerInfixExpression(
 		return &object.Float{Value: leftVal * rightVal}
 	case "/":
 		return &object.Float{Value: leftVal / rightVal}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -556,6 +782,8 @@ func evalFloatInfixExpression(
 		return &object.Float{Value: leftVal * rightVal}
 	case "/":
 		return &object.Float{Value: leftVal / rightVal}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -589,9 +817,24 @@ func evalIntegerInfixExpression(
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		if rightVal < 0 {
+			return &object.Float{Value: math.Pow(float64(leftVal), float64(rightVal))}
+		}
+		return &object.Integer{Value: integerPower(leftVal, rightVal)}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -610,9 +853,22 @@ func evalIntegerInfixExpression(
 	}
 }
 
+// integerPower returns base ** exp for exp >= 0 by squaring.
+func integerPower(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+	if isAbrupt(condition) {
 		return condition
 	}
 
@@ -620,11 +876,25 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 		return Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
 		return Eval(ie.Alternative, env)
+	} else if ie.ElseIf != nil {
+		return Eval(ie.ElseIf, env)
 	} else {
 		return NULL
 	}
 }
 
+func evalConditionalExpression(ce *ast.ConditionalExpression, env *object.Environment) object.Object {
+	condition := Eval(ce.Condition, env)
+	if isAbrupt(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return Eval(ce.Consequence, env)
+	}
+	return Eval(ce.Alternative, env)
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -639,7 +909,49 @@ func isTruthy(obj object.Object) bool {
 }
 
 func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	return newKindError(object.RUNTIME_ERROR, format, a...)
+}
+
+func newKindError(kind string, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
+}
+
+// checkMutable returns an error if obj is a frozen array or hash.
+func checkMutable(obj object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Array:
+		if obj.Frozen {
+			return newError("cannot modify frozen ARRAY")
+		}
+	case *object.Hash:
+		if obj.Frozen {
+			return newError("cannot modify frozen HASH")
+		}
+	}
+	return nil
+}
+
+// freeze makes obj, and the arrays and hashes it contains, immutable.
+func freeze(obj object.Object) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		if obj.Frozen {
+			return
+		}
+		obj.Frozen = true
+		for _, el := range obj.Elements {
+			freeze(el)
+		}
+	case *object.Hash:
+		if obj.Frozen {
+			return
+		}
+		obj.Frozen = true
+		for _, pair := range obj.Pairs() {
+			freeze(pair.Key)
+			freeze(pair.Value)
+		}
+	}
 }
 
 func isError(obj object.Object) bool {
@@ -648,3 +960,16 @@ func isError(obj object.Object) bool {
 	}
 	return false
 }
+
+func isReturnValue(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.RETURN_VALUE_OBJ
+	}
+	return false
+}
+
+// isAbrupt reports whether obj ends the evaluation of the enclosing
+// expressions: an error, or a value returned early by the ? operator.
+func isAbrupt(obj object.Object) bool {
+	return isError(obj) || isReturnValue(obj)
+}
@@ -4,6 +4,11 @@ import (
 	"Nutlang/lexer"
 	"Nutlang/object"
 	"Nutlang/parser"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -104,26 +109,50 @@ func TestHashLiterals(t *testing.T) {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := []struct {
+		key   object.Object
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for _, tt := range expected {
+		value, ok := result.Get(tt.key)
 		if !ok {
-			t.Errorf("no pair for given key in Pairs")
+			t.Errorf("no pair for key %s in Pairs", tt.key.Inspect())
+			continue
 		}
 
-		testIntegerObject(t, pair.Value, expectedValue)
+		testIntegerObject(t, value, tt.value)
+	}
+}
+
+func TestHashInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{}`, `{}`},
+		{`{"b": 1, "a": 2, "c": 3}`, `{b: 1, a: 2, c: 3}`},
+		{`let h = {"b": 1, "a": 2}; h["z"] = 0; h["b"] = 5; h`, `{b: 5, a: 2, z: 0}`},
+		{`{3: 1, 1: 1, 2: 1, [0, 1]: 1}`, `{3: 1, 1: 1, 2: 1, [0, 1]: 1}`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
 	}
 }
 
@@ -160,6 +189,42 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{1.5: 5}[1.5]`,
+			5,
+		},
+		{
+			`{1: 5}[1.0]`,
+			5,
+		},
+		{
+			`{2.0: 5}[2]`,
+			5,
+		},
+		{
+			`{[1, 2]: 5}[[1, 2]]`,
+			5,
+		},
+		{
+			`{[1, 2]: 5}[[2, 1]]`,
+			nil,
+		},
+		{
+			`{[1, [2, "a"]]: 5}[[1, [2, "a"]]]`,
+			5,
+		},
+		{
+			`let k = [1, 2]; let h = {k: 5}; k[0] = 3; h[[1, 2]]`,
+			5,
+		},
+		{
+			`let h = {}; h[[0, 1]] = 5; h[[0, 1]]`,
+			5,
+		},
+		{
+			`{first([]): 5}[first([])]`,
+			5,
+		},
 	}
 
 	for _, tt := range tests {
@@ -278,6 +343,111 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = 1; let y = 2; "pos=${x},${y}"`, "pos=1,2"},
+		{`"${1 + 2}"`, "3"},
+		{`let name = "nut"; "hi ${name}!"`, "hi nut!"},
+		{`"${[1, 2]} ${true} ${1.5}"`, "[1, 2] true 1.500000"},
+		{`let h = {"a": 1}; "a=${h["a"]}"`, "a=1"},
+		{`"outer ${"inner ${1}"}"`, "outer inner 1"},
+		{`let f = fn() {}; "got ${f()}"`, "got null"},
+		{`"got ${if (true) {}}"`, "got null"},
+		{`"cost: \${5}"`, "cost: ${5}"},
+		{`"$5"`, "$5"},
+		{`str(5) + "!"`, "5!"},
+		{`str([1, "a"])`, "[1, a]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. want=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+
+	evaluated := testEval(`"${missing}"`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "identifier not found: missing" {
+		t.Errorf("expected identifier error. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestCharExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`'a'`, 'a'},
+		{`'c' - 'a'`, 2},
+		{`'a' + 2`, 'c'},
+		{`1 + 'a'`, 'b'},
+		{`'z' - 1`, 'y'},
+		{`'a' < 'b'`, true},
+		{`'b' <= 'a'`, false},
+		{`'a' == 'a'`, true},
+		{`'a' == "a"`, false},
+		{`"ab" + 'c'`, "abc"},
+		{`'x' + "yz"`, "xyz"},
+		{`"héllo"[1]`, 'é'},
+		{`"héllo"[2]`, 'l'},
+		{`"héllo"[4]`, 'o'},
+		{`"héllo"[5]`, nil},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, nil},
+		{`let h = {'a': 1}; h['a']`, 1},
+		{`let s = "a1b2"; let n = 0; for (let i = 0; i < len(s); i = i + 1) { if (isDigit(s[i])) { n = n + 1 } }; n`, 2},
+		{`chars("ab")[1]`, 'b'},
+		{`chr(97)`, 'a'},
+		{`ord('a')`, 97},
+		{`includes("abc", 'b')`, true},
+		{`'a' * 2`, "unknown operator: CHAR * INTEGER"},
+		{`'a' + true`, "type mismatch: CHAR + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case rune:
+			c, ok := evaluated.(*object.Char)
+			if !ok {
+				t.Errorf("object is not Char. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if c.Value != expected {
+				t.Errorf("Char has wrong value. want=%q, got=%q", expected, c.Value)
+			}
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("String has wrong value. want=%q, got=%q", expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. want=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("unexpected object %T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
 func TestEvalFloatExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -350,6 +520,11 @@ func testEval(input string) object.Object {
 	return Eval(program, env)
 }
 
+func testEvalWithStreams(input string, streams *object.Streams) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	return Eval(program, object.NewEnvironmentWithStreams(streams))
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -395,6 +570,25 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" + "b" == "ab"`, true},
+		{"1 == 1.0", true},
+		{"1.5 != 1", true},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] != [1, 2, 3]", true},
+		{"[1, 2.0] == [1.0, 2]", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`1 == "1"`, false},
+		{`1 != "1"`, true},
+		{"[1] == 1", false},
+		{"let f = fn(x) { x }; f == f", true},
+		{"fn(x) { x } == fn(x) { x }", false},
 	}
 
 	for _, tt := range tests {
@@ -444,6 +638,8 @@ func TestForWhileExpressions(t *testing.T) {
 		{"for (false) { 10 }", nil},
 		{"let x = 5; for (x < 5) { 10 }", nil},
 		{"let x = 5; for (x < 6) { x = x + 1 }", 6},
+		{"let f = fn() { let x = 0; for (true) { x = x + 1; if (x == 3) { return x } } }; f()", 3},
+		{"let f = fn() { for (let i = 0; i < 10; i = i + 1) { if (i == 4) { return i } } }; f()", 4},
 	}
 
 	for _, tt := range tests {
@@ -469,6 +665,15 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", nil},
+		{"let x = 5; if (x < 0) { 1 } else if (x < 3) { 2 } else if (x < 6) { 3 } else { 4 }", 3},
+		{"true ? 1 : 2", 1},
+		{"0 < -1 ? 1 : 2", 2},
+		{"false ? 1 : true ? 2 : 3", 2},
+		{"let sign = fn(n) { n < 0 ? -1 : n == 0 ? 0 : 1 }; sign(-5) + sign(0) * 10 + sign(7) * 100", 99},
+		{"let r = tryCall(fn() { error(\"x\")? }); isError(r[1]) ? 1 : 2", 1},
 	}
 
 	for _, tt := range tests {
@@ -527,6 +732,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`let s = "héllo"; [s[len(s) - 1], s[len(s)], s[indexOf(s, "l")], indexOf(s, "o")]`, object.String{Value: "[o, null, l, 4]"}},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		{`len([1, 2, 3])`, 3},
@@ -580,7 +787,13 @@ func TestBuiltinFunctions(t *testing.T) {
 
 		{`includes()`, "wrong number of arguments. got=0, want=2"},
 		{`includes(1, 2)`, "argument 1 to `includes` not supported, got INTEGER"},
-		{`includes([1], [2])`, "argument 2 to `includes` not supported, got ARRAY"},
+		{`includes("abc", [2])`, "argument 2 to `includes` not supported, got ARRAY"},
+		{`includes([1], [2])`, false},
+		{`includes([[1, 2], [3]], [1, 2])`, true},
+		{`includes([1, 2.5], 2.5)`, true},
+		{`includes([1, 2], 2.0)`, true},
+		{`includes([{"a": 1}], {"a": 1})`, true},
+		{`let a = [1, 0]; a[1] = a; let b = [1, 0]; b[1] = b; [a == b, includes([a], b)]`, object.String{Value: "[true, true]"}},
 		{`includes("abc", "a")`, true},
 		{`includes("abc", "c")`, true},
 		{`includes("abc", "d")`, false},
@@ -610,31 +823,266 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`trim("bab", "b")`, object.String{Value: "a"}},
 		{`trim("abc", "b")`, object.String{Value: "abc"}},
 		{`trim("   abc  ", " ")`, object.String{Value: "abc"}},
+
+		{`len({})`, 0},
+		{`len({"a": 1, "b": 2})`, 2},
+
+		{`keys({"b": 1, "a": 2})`, object.String{Value: "[b, a]"}},
+		{`keys({})`, object.String{Value: "[]"}},
+		{`keys([])`, "argument to `keys` must be HASH, got ARRAY"},
+		{`values({"b": 1, "a": 2})`, []int{1, 2}},
+		{`values()`, "wrong number of arguments. got=0, want=1"},
+		{`entries({"b": 1, 2: [3]})`, object.String{Value: "[[b, 1], [2, [3]]]"}},
+		{`entries(1)`, "argument to `entries` must be HASH, got INTEGER"},
+
+		{`fromEntries([["a", 1], [[0, 1], 2]])`, object.String{Value: "{a: 1, [0, 1]: 2}"}},
+		{`fromEntries(entries({"x": 1, "y": 2})) == {"x": 1, "y": 2}`, true},
+		{`fromEntries([["a", 1], ["b"]])`, "entry 1 to `fromEntries` must be a [key, value] ARRAY, got [b]"},
+		{`fromEntries([[fn(x) { x }, 1]])`, "unusable as hash key: FUNCTION"},
+		{`fromEntries({})`, "argument to `fromEntries` must be ARRAY, got HASH"},
+
+		{`has({"a": 1}, "a")`, true},
+		{`has({"a": 1}, "b")`, false},
+		{`has({[1, 2]: 1}, [1, 2])`, true},
+		{`has({}, [fn(x) { x }])`, "unusable as hash key: ARRAY"},
+		{`has([], 1)`, "argument 1 to `has` must be HASH, got ARRAY"},
+
+		{`let h = {"a": 1, "b": 2}; delete(h, "a")`, true},
+		{`let h = {"a": 1, "b": 2}; delete(h, "c")`, false},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b"); h`, object.String{Value: "{a: 1, c: 3}"}},
+		{`delete({"a": 1})`, "wrong number of arguments. got=1, want=2"},
+		{`delete([1], 0)`, "argument 1 to `delete` must be HASH, got ARRAY"},
+
+		{`getOr({"a": 1}, "a", 0)`, 1},
+		{`getOr({"a": 1}, "b", 0)`, 0},
+		{`getOr({}, "b")`, "wrong number of arguments. got=2, want=3"},
+		{`getOr(1, "b", 0)`, "argument 1 to `getOr` must be HASH, got INTEGER"},
+
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, object.String{Value: "{a: 1, b: 3, c: 4}"}},
+		{`let h = {"a": 1}; merge(h, {"b": 2}); h`, object.String{Value: "{a: 1}"}},
+		{`merge()`, "wrong number of arguments. got=0, want at least 1"},
+		{`merge({}, [])`, "argument 2 to `merge` must be HASH, got ARRAY"},
+
+		{`map([1, 2, 3], fn(x) { x * 2 })`, []int{2, 4, 6}},
+		{`map([5, 5], fn(x, i) { x + i })`, []int{5, 6}},
+		{`map([], fn(x) { x })`, []int{}},
+		{`map(["ab", "c"], len)`, []int{2, 1}},
+		{`map([1], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`map([1], fn(x, y, z) { x })`, "wrong number of arguments. got=2, want=3"},
+		{`reduce([1, 2], fn(acc, x, i) { acc + x * i }, 0)`, 2},
+		{`map([1], 1)`, "not a function: INTEGER"},
+		{`map(1, fn(x) { x })`, "argument 1 to `map` must be ARRAY, got INTEGER"},
+		{`let k = 10; map([1], fn(x) { x + k })`, []int{11}},
+
+		{`filter([1, 2, 3, 4], fn(x) { x % 2 == 0 })`, []int{2, 4}},
+		{`filter([1, 2], fn(x) { false })`, []int{}},
+		{`filter([1])`, "wrong number of arguments. got=1, want=2"},
+
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x })`, 6},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)`, 16},
+		{`reduce([], fn(acc, x) { acc + x }, 10)`, 10},
+		{`reduce([], fn(acc, x) { acc + x })`, "`reduce` of empty ARRAY with no initial value"},
+
+		{`sort([3, 1, 2])`, []int{1, 2, 3}},
+		{`sort([2.5, 1, 2])`, object.String{Value: "[1, 2, 2.500000]"}},
+		{`sort(["b", "c", "a"])`, object.String{Value: "[a, b, c]"}},
+		{`sort([[1, 2], [0, 5], [1]])`, object.String{Value: "[[0, 5], [1], [1, 2]]"}},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, []int{3, 2, 1}},
+		{`sort([3, 1, 2], fn(a, b) { b - a })`, []int{3, 2, 1}},
+		{`sort([[1, "a"], [0, "b"], [1, "c"], [0, "d"]], fn(a, b) { a[0] < b[0] })`,
+			object.String{Value: "[[0, b], [0, d], [1, a], [1, c]]"}},
+		{`let a = [2, 1]; sort(a); a`, []int{2, 1}},
+		{`sort([1, "a"])`, "cannot compare STRING with INTEGER"},
+		{`sort([1, 2], fn(a, b) { "x" })`, "comparator must return BOOLEAN or INTEGER, got STRING"},
+
+		{`sortBy(["ccc", "a", "bb"], fn(s) { len(s) })`, object.String{Value: "[a, bb, ccc]"}},
+		{`sortBy([[2, "x"], [1, "y"], [2, "a"]], fn(p) { p[0] })`,
+			object.String{Value: "[[1, y], [2, x], [2, a]]"}},
+
+		{`find([1, 2, 3], fn(x) { x > 1 })`, 2},
+		{`find([1, 2, 3], fn(x) { x > 5 })`, object.Null{}},
+		{`findIndex([1, 2, 3], fn(x) { x > 1 })`, 1},
+		{`findIndex([1, 2, 3], fn(x) { x > 5 })`, -1},
+
+		{`any([1, 2, 3], fn(x) { x > 2 })`, true},
+		{`any([], fn(x) { true })`, false},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, true},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, false},
+
+		// Callbacks with an empty body return null
+		{`map([1], fn(x) {})`, object.String{Value: "[null]"}},
+		{`filter([1, 2], fn(x) {})`, []int{}},
+		{`reduce([1, 2], fn(acc, x) {}, 0)`, object.Null{}},
+		{`sort([2, 1], fn(a, b) {})`, "comparator must return BOOLEAN or INTEGER, got NULL"},
+		{`sortBy([1, 2], fn(x) {})`, "cannot compare NULL with NULL"},
+		{`find([1], fn(x) {})`, object.Null{}},
+		{`findIndex([1], fn(x) {})`, -1},
+		{`any([1], fn(x) {})`, false},
+		{`all([1], fn(x) {})`, false},
+
+		{`zip([1, 2, 3], ["a", "b"])`, object.String{Value: "[[1, a], [2, b]]"}},
+		{`zip([1], [2], [3])`, object.String{Value: "[[1, 2, 3]]"}},
+		{`zip([1], 2)`, "argument 2 to `zip` must be ARRAY, got INTEGER"},
+		{`enumerate(["a", "b"])`, object.String{Value: "[[0, a], [1, b]]"}},
+
+		{`flatten([1, [2, [3]], []])`, object.String{Value: "[1, 2, [3]]"}},
+		{`flatten([1, [2, [3, [4]]]], 10)`, []int{1, 2, 3, 4}},
+		{`flatten([1], "a")`, "argument 2 to `flatten` must be INTEGER, got STRING"},
+
+		{`reverse([1, 2, 3])`, []int{3, 2, 1}},
+		{`reverse("abc")`, "argument to `reverse` must be ARRAY, got STRING"},
+
+		{`join([1, "a", true], "-")`, object.String{Value: "1-a-true"}},
+		{`join(["a", "b"])`, object.String{Value: "ab"}},
+		{`join([], ",")`, object.String{Value: ""}},
+
+		{`sum([1, 2, 3])`, 6},
+		{`sum([])`, 0},
+		{`sum([1, 0.5])`, object.String{Value: "1.500000"}},
+		{`sum([1, "a"])`, "`sum` expects INTEGER or FLOAT elements, got STRING"},
+
+		{`range(3)`, []int{0, 1, 2}},
+		{`range(2, 5)`, []int{2, 3, 4}},
+		{`range(5, 0, -2)`, []int{5, 3, 1}},
+		{`range(0)`, []int{}},
+		{`range(9223372036854775806, 9223372036854775807, 10)`, object.String{Value: "[9223372036854775806]"}},
+		{`range(-9223372036854775807, -9223372036854775807 - 1, -5)`, object.String{Value: "[-9223372036854775807]"}},
+		{`range(0, 5, 0)`, "`range` step must not be 0"},
+		{`range("a")`, "argument 1 to `range` must be INTEGER, got STRING"},
+
+		{`indexOf([1, [2], 3], [2])`, 1},
+		{`indexOf([1, 2], 5)`, -1},
+
+		{`uniq([1, 2, 1, 3, 2.0])`, []int{1, 2, 3}},
+		{`uniq([[1], [1], {"a": 1}, {"a": 1}])`, object.String{Value: "[[1], {a: 1}]"}},
+
+		{`chunk([1, 2, 3, 4, 5], 2)`, object.String{Value: "[[1, 2], [3, 4], [5]]"}},
+		{`chunk([], 2)`, object.String{Value: "[]"}},
+		{`chunk([1], 0)`, "`chunk` size must be positive, got 0"},
+
+		{`upper("abC1")`, object.String{Value: "ABC1"}},
+		{`upper(1)`, "argument to `upper` must be STRING, got INTEGER"},
+		{`lower("AbC")`, object.String{Value: "abc"}},
+		{`lower()`, "wrong number of arguments. got=0, want=1"},
+
+		{`replace("a-b-c", "-", "+")`, object.String{Value: "a+b+c"}},
+		{`replace("a-b-c", "-", "", 1)`, object.String{Value: "ab-c"}},
+		{`replace("a", 1, "b")`, "argument 2 to `replace` must be STRING, got INTEGER"},
+		{`replace("a", "a", "b", "c")`, "argument 4 to `replace` must be INTEGER, got STRING"},
+
+		{`startsWith("hello", "he")`, true},
+		{`startsWith("hello", "lo")`, false},
+		{`endsWith("hello", "lo")`, true},
+		{`endsWith("hello", 1)`, "argument 2 to `endsWith` must be STRING, got INTEGER"},
+
+		{`indexOf("hello", "l")`, 2},
+		{`indexOf("hello", "z")`, -1},
+		{`indexOf("héllo", "l")`, 2},
+		{`indexOf("hello", 1)`, "argument 2 to `indexOf` must be STRING, got INTEGER"},
+		{`indexOf(1, 1)`, "argument 1 to `indexOf` not supported, got INTEGER"},
+
+		{`repeat("ab", 3)`, object.String{Value: "ababab"}},
+		{`repeat("ab", -1)`, "argument 2 to `repeat` must not be negative, got -1"},
+		{`repeat("ab", 4611686018427387904)`, "result of `repeat` would exceed 1073741824 bytes"},
+
+		{`padLeft("7", 3)`, object.String{Value: "  7"}},
+		{`padLeft("7", 3, "0")`, object.String{Value: "007"}},
+		{`padLeft("1234", 3, "0")`, object.String{Value: "1234"}},
+		{`padLeft("ab", 4611686018427387904)`, "result of `padLeft` would exceed 1073741824 bytes"},
+		{`padRight("a", 400000000, "€")`, "result of `padRight` would exceed 1073741824 bytes"},
+		{`padLeft("x", 4, "€a")`, object.String{Value: "€a€x"}},
+		{`padRight("ab", 5, "xy")`, object.String{Value: "abxyx"}},
+		{`padRight("é", 2, ".")`, object.String{Value: "é."}},
+		{`padRight("a", 2, "")`, "argument 3 to `padRight` must not be empty"},
+		{`padLeft("a")`, "wrong number of arguments. got=1, want=2 or 3"},
+
+		{`chars("héy")`, object.String{Value: "[h, é, y]"}},
+		{`chars("")`, object.String{Value: "[]"}},
+		{`splitLines("a\nb\r\nc\n")`, object.String{Value: "[a, b, c]"}},
+		{`splitLines("a\n\nb")`, object.String{Value: "[a, , b]"}},
+		{`splitLines("")`, object.String{Value: "[]"}},
+		{`splitWhitespace("  a b\t\nc ")`, object.String{Value: "[a, b, c]"}},
+
+		{`ord("a")`, 97},
+		{`ord("é")`, 233},
+		{`ord("ab")`, "argument to `ord` must be a single character, got \"ab\""},
+		{`chr(97)`, object.String{Value: "a"}},
+		{`chr(-1)`, "argument to `chr` out of range, got -1"},
+		{`chr("a")`, "argument to `chr` must be INTEGER, got STRING"},
+
+		{`isDigit("0123")`, true},
+		{`isDigit("12a")`, false},
+		{`isDigit("")`, false},
+		{`isAlpha("abC")`, true},
+		{`isAlpha("ab1")`, false},
+
+		{`format("%s=%d", "x", 5)`, object.String{Value: "x=5"}},
+		{`format("%v|%5s|%-3d|", [1, "a"], "ab", 7)`, object.String{Value: "[1, a]|   ab|7  |"}},
+		{`format("%.2f %.1f", 1.005, 2)`, object.String{Value: "1.00 2.0"}},
+		{`format("%q %t %x 100%%", "a", true, 255)`, object.String{Value: "\"a\" true ff 100%"}},
+		{`sprintf("%03d", 7)`, object.String{Value: "007"}},
+		{`format("%d", "a")`, "`format`: %d wants INTEGER, got STRING"},
+		{`format("%f", true)`, "`format`: %f wants INTEGER or FLOAT, got BOOLEAN"},
+		{`format("%d %d", 1)`, "`format`: missing value for %d"},
+		{`format("%d", 1, 2)`, "`format`: 1 unused values"},
+		{`format("%y", 1)`, "`format`: unknown verb %y"},
+		{`format("%", 1)`, "`format`: format ends in the middle of a verb"},
+		{`sprintf(1)`, "argument 1 to `sprintf` must be STRING, got INTEGER"},
+
+		{`r"a+b"`, object.String{Value: `r"a+b"`}},
+		{`regex("\\d+") == r"\d+"`, true},
+		{`regex("(")`, "invalid regular expression \"(\": error parsing regexp: missing closing ): `(`"},
+		{`regex(1)`, "argument to `regex` must be REGEX or STRING, got INTEGER"},
+
+		{`match(r"(\d+)-(\d+)", "x 10-20 y")`, object.String{Value: "[10-20, 10, 20]"}},
+		{`match("a(b)?", "ac")`, object.String{Value: "[a, null]"}},
+		{`match(r"\d", "abc")`, object.Null{}},
+		{`match(r"(?P<x>\d+),(?P<y>\d+)", "3,4")`, object.String{Value: "{0: 3,4, x: 3, y: 4}"}},
+		{`match(r"x", 1)`, "argument 2 to `match` must be STRING, got INTEGER"},
+		{`matchAll(r"(\w)=(\d)", "a=1 b=2")`, object.String{Value: "[[a=1, a, 1], [b=2, b, 2]]"}},
+		{`matchAll(r"z", "abc")`, object.String{Value: "[]"}},
+		{`findAll(r"-?\d+", "1, -2 and 30")`, object.String{Value: "[1, -2, 30]"}},
+		{`findAll(r"\d", "none")`, object.String{Value: "[]"}},
+
+		{`replaceRegex(r"(\w+)@(\w+)", "me@host", "$2 at $1")`, object.String{Value: "host at me"}},
+		{`replaceRegex(r"\d+", "a1b22", fn(m) { len(m[0]) })`, object.String{Value: "a1b2"}},
+		{`replaceRegex(r"\d", "a1", fn(m) { m + 1 })`, "type mismatch: ARRAY + INTEGER"},
+		{`replaceRegex(r"\d", "a1", fn(m) {})`, object.String{Value: "anull"}},
+		{`replaceRegex(r"\d", "a1", 1)`, "argument 3 to `replaceRegex` must be STRING or FUNCTION, got INTEGER"},
+
+		{`splitRegex(r"\s*,\s*", "a , b,c")`, object.String{Value: "[a, b, c]"}},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
 
 		switch expected := tt.expected.(type) {
 		case bool:
-			if evaluated.Type() == object.BOOLEAN_OBJ {
-				eval := evaluated.(*object.Boolean)
-				if expected != eval.Value {
-					t.Errorf("expected=%t, got=%t", expected, eval.Value)
-				}
-			}
+			testBooleanObject(t, evaluated, expected)
 		case []int:
-			if evaluated.Type() == object.ARRAY_OBJ {
-				eval := evaluated.(*object.Array)
-				for i := range expected {
-					testIntegerObject(t, eval.Elements[i], int64(expected[i]))
-				}
+			eval, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(eval.Elements) != len(expected) {
+				t.Errorf("array has wrong num of elements. want=%d, got=%d",
+					len(expected), len(eval.Elements))
+				continue
+			}
+			for i := range expected {
+				testIntegerObject(t, eval.Elements[i], int64(expected[i]))
 			}
 		case object.String:
-			if evaluated.Type() == object.STRING_OBJ {
+			switch evaluated.Type() {
+			case object.STRING_OBJ:
 				if evaluated.(*object.String).Value != expected.Value {
 					t.Errorf("expected=%s, got=%s", expected.Value, evaluated.(*object.String).Value)
 				}
-			} else {
+			case object.ARRAY_OBJ, object.HASH_OBJ, object.FLOAT_OBJ, object.REGEX_OBJ, object.CHAR_OBJ:
+				if evaluated.Inspect() != expected.Value {
+					t.Errorf("expected=%s, got=%s", expected.Value, evaluated.Inspect())
+				}
+			default:
 				t.Errorf("expected value of type STRING, got=%s", evaluated.Type())
 			}
 		case object.Null:
@@ -660,6 +1108,566 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestFileBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "input.txt")
+	runtime := object.NewEnvironment().Runtime()
+	defer runtime.CloseFiles()
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`writeFile(path, "a\nb\r\n")`, nil},
+		{`appendFile(path, "c")`, nil},
+		{`readFile(path)`, "a\nb\r\nc"},
+		{`exists(path)`, true},
+		{`exists(path + ".missing")`, false},
+		{`let f = open(path); [readLine(f), readAll(f)]`, "[a, b\r\nc]"},
+		{`let f = open(path); readLine(f); readLine(f); readLine(f); readLine(f)`, nil},
+		{`collect(lines(open(path)))`, "[a, b, c]"},
+		{`let it = lines(open(path)); next(it); next(it)`, "b"},
+		{`let it = lines(open(path)); collect(it); next(it)`, nil},
+		{`collect(lines(path))`, "[a, b, c]"},
+		{`let it = lines(path); next(it)`, "a"},
+		{`lines(path + ".missing")`, "error in `lines`: open " + path + ".missing: no such file or directory"},
+		{`lines(1)`, "argument to `lines` must be STRING or FD, got INTEGER"},
+		{`collect(lines(dir))`, "error in `lines`: read " + dir + ": is a directory"},
+		{`let it = lines(dir); next(it)`, "error in `lines`: read " + dir + ": is a directory"},
+		{`let it = lines(dir); try { next(it) } catch (e) { 0 }; next(it)`, nil},
+		{`let f = open(path, "r+"); readLine(f); write(f, "X"); close(f); readFile(path)`, "a\nX\r\nc"},
+		{`let f = open(path, "r+"); readLine(f); write(f, "B"); readAll(f)`, "\r\nc"},
+		{`let f = open(path, "w"); write(f, "x"); write(f, 1); close(f); readFile(path)`, "x1"},
+		{`let f = open(path); close(f); close(f)`, "file " + path + " already closed"},
+		{`let f = open(path); close(f); readLine(f)`, "file " + path + " already closed"},
+		{`open(path, "q")`, "unknown file mode \"q\""},
+		{`open(path + ".missing")`, "error in `open`: open " + path + ".missing: no such file or directory"},
+		{`readLine(1)`, "argument to `readLine` must be FD, got INTEGER"},
+		{`mkdir(path + ".d/sub"); listDir(path + ".d")`, "[sub]"},
+		{`removeFile(path + ".d/sub"); listDir(path + ".d")`, "[]"},
+		{`removeFile(path); exists(path)`, false},
+		{`removeFile(path)`, "error in `removeFile`: remove " + path + ": no such file or directory"},
+		{`removeFile([1])`, "argument to `removeFile` must be STRING, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironmentWithRuntime(runtime)
+		env.Set("path", &object.String{Value: path})
+		env.Set("dir", &object.String{Value: dir})
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+
+		switch expected := tt.expected.(type) {
+		case nil:
+			testNullObject(t, evaluated)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message for %q. want=%q, got=%q",
+						tt.input, expected, obj.Message)
+				}
+			default:
+				if obj.Inspect() != expected {
+					t.Errorf("wrong result for %q. want=%q, got=%q",
+						tt.input, expected, obj.Inspect())
+				}
+			}
+		}
+	}
+
+	if len(runtime.Files) == 0 {
+		t.Fatalf("expected unclosed files to be tracked")
+	}
+
+	runtime.CloseFiles()
+	if len(runtime.Files) != 0 {
+		t.Errorf("CloseFiles left %d files open", len(runtime.Files))
+	}
+}
+
+func TestStdinBuiltins(t *testing.T) {
+	tests := []struct {
+		stdin    string
+		input    string
+		expected string
+	}{
+		{"a\nb\n", `[readLine(), readLine(), readLine()]`, "[a, b, null]"},
+		{"a\r\nb", `[readLine(), readStdin()]`, "[a, b]"},
+		{"", `readLine()`, "null"},
+		{"1\n2\n3", `readLine(); collect(stdinLines())`, "[2, 3]"},
+		{"x\ny\n", `let it = stdinLines(); [next(it), readLine(), next(it)]`, "[x, y, null]"},
+		{"a\n", `let f = fn() { readLine() }; f()`, "a"},
+		{"", `readStdin(1)`, "ERROR: wrong number of arguments. got=1, want=0"},
+	}
+
+	for _, tt := range tests {
+		streams := object.NewStreams(strings.NewReader(tt.stdin), io.Discard, io.Discard)
+		evaluated := testEvalWithStreams(tt.input, streams)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestOutputBuiltins(t *testing.T) {
+	tests := []struct {
+		input  string
+		stdout string
+		stderr string
+	}{
+		{`puts("a", 1)`, "a\n1\n", ""},
+		{`print("a", 1); print([2])`, "a 1[2]", ""},
+		{`eprint("oops", 1)`, "", "oops 1"},
+		{`printf("%d-%s\n", 1, "a")`, "1-a\n", ""},
+		{`map([1, 2], fn(x) { puts(x) })`, "1\n2\n", ""},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		streams := object.NewStreams(strings.NewReader(""), &stdout, &stderr)
+		evaluated := testEvalWithStreams(tt.input, streams)
+		if isError(evaluated) {
+			t.Errorf("unexpected error for %q: %s", tt.input, evaluated.Inspect())
+		}
+		if stdout.String() != tt.stdout {
+			t.Errorf("wrong stdout for %q. want=%q, got=%q", tt.input, tt.stdout, stdout.String())
+		}
+		if stderr.String() != tt.stderr {
+			t.Errorf("wrong stderr for %q. want=%q, got=%q", tt.input, tt.stderr, stderr.String())
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input    string
+		limits   object.Limits
+		expected string
+	}{
+		{`for (true) { 1 }`, object.Limits{MaxSteps: 1000}, "step limit of 1000 exceeded"},
+		{`let f = fn(n) { f(n + 1) }; f(0)`, object.Limits{MaxCallDepth: 50}, "maximum call depth of 50 exceeded"},
+		{`let f = fn(n) { f(n + 1) }; f(0)`, object.Limits{}, "maximum call depth of 10000 exceeded"},
+		{`let s = ""; for (true) { s = s + "abc" }`, object.Limits{MaxAllocations: 1000}, "allocation limit of 1000 exceeded"},
+		{`let a = []; for (true) { a = push(a, 1) }`, object.Limits{MaxAllocations: 1000}, "allocation limit of 1000 exceeded"},
+		{`for (true) { 1 }`, object.Limits{Context: cancelled}, "execution cancelled: context canceled"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100)`, object.Limits{MaxCallDepth: 101}, "100"},
+		{`let a = [1, 2, 3]; len(a)`, object.Limits{MaxSteps: 100, MaxAllocations: 3}, "3"},
+		// Builtins check the budget before building their result
+		{`range(0, 50000000)`, object.Limits{MaxAllocations: 1000}, "allocation limit of 1000 exceeded"},
+		{`repeat("ab", 100000)`, object.Limits{MaxAllocations: 1000}, "allocation limit of 1000 exceeded"},
+		{`padLeft("a", 100000)`, object.Limits{MaxAllocations: 1000}, "allocation limit of 1000 exceeded"},
+		{`sort(range(0, 600))`, object.Limits{MaxAllocations: 1000}, "allocation limit of 1000 exceeded"},
+		{`len(range(0, 1000))`, object.Limits{MaxAllocations: 1000}, "1000"},
+		{`range(0, 50000000)`, object.Limits{Context: cancelled}, "execution cancelled: context canceled"},
+		// Limit errors cannot be caught, and stop the program for good
+		{`let n = 0; for (true) { try { for (true) { n = n + 1 } } catch (e) { n = 0 } }`,
+			object.Limits{MaxSteps: 10000}, "step limit of 10000 exceeded"},
+		{`let n = 0; for (true) { try { for (true) { n = n + 1 } } catch (e) { n = 0 } }`,
+			object.Limits{Context: cancelled}, "execution cancelled: context canceled"},
+		{`let f = fn() { try { f() } catch (e) { f() } }; f()`, object.Limits{MaxCallDepth: 50}, "maximum call depth of 50 exceeded"},
+		{`let f = fn() { try { f() } finally { f() } }; f()`, object.Limits{MaxCallDepth: 50}, "maximum call depth of 50 exceeded"},
+		{`let s = ""; try { for (true) { s = s + "abc" } } catch (e) { 1 }; "x" + "y"`,
+			object.Limits{MaxAllocations: 1000}, "allocation limit of 1000 exceeded"},
+	}
+
+	for _, tt := range tests {
+		runtime := &object.Runtime{Streams: object.NewStreams(strings.NewReader(""), io.Discard, io.Discard), Limits: tt.limits}
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := Eval(program, object.NewEnvironmentWithRuntime(runtime))
+
+		result := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			result = errObj.Message
+		}
+		if result != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, result)
+		}
+		if runtime.CallDepth != 0 {
+			t.Errorf("call depth not restored for %q. got=%d", tt.input, runtime.CallDepth)
+		}
+	}
+
+	// A limit hit while hoisting a function leaves its name unbound
+	runtime := &object.Runtime{Limits: object.Limits{MaxSteps: 1}}
+	env := object.NewEnvironmentWithRuntime(runtime)
+	evaluated := Eval(parser.New(lexer.New(`fn f() { 1 }`)).ParseProgram(), env)
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Message != "step limit of 1 exceeded" {
+		t.Errorf("expected step limit error while hoisting, got %s", evaluated.Inspect())
+	}
+	if f, ok := env.Get("f"); ok {
+		t.Errorf("hoisted function bound to %s", f.Inspect())
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(root, "in.txt"), []byte("in"), 0o644)
+	os.WriteFile(filepath.Join(outside, "out.txt"), []byte("out"), 0o644)
+	os.Symlink(outside, filepath.Join(root, "link"))
+
+	tests := []struct {
+		input    string
+		denied   []object.Capability
+		expected string
+	}{
+		{`readFile(root + "/in.txt")`, nil, "in"},
+		{`readFile(root + "/in.txt")`, []object.Capability{object.FS_READ_CAP}, "permission denied: `readFile` needs the fs-read capability"},
+		{`readFile(outside + "/out.txt")`, nil, "permission denied: `readFile` cannot access " + outside + "/out.txt outside of the allowed directories"},
+		{`readFile(root + "/../" + "x")`, nil, "permission denied: `readFile` cannot access " + root + "/../x outside of the allowed directories"},
+		{`readFile(root + "/link/out.txt")`, nil, "permission denied: `readFile` cannot access " + root + "/link/out.txt outside of the allowed directories"},
+		{`writeFile(root + "/new/x.txt", "x")`, []object.Capability{object.FS_WRITE_CAP}, "permission denied: `writeFile` needs the fs-write capability"},
+		{`mkdir(root + "/new/dir"); exists(root + "/new/dir")`, nil, "true"},
+		{`open(root + "/in.txt", "r")`, []object.Capability{object.FS_WRITE_CAP}, "fd(" + root + "/in.txt)"},
+		{`open(root + "/in.txt", "a")`, []object.Capability{object.FS_WRITE_CAP}, "permission denied: `open` needs the fs-write capability"},
+		{`open(root + "/in.txt", "r+")`, []object.Capability{object.FS_READ_CAP}, "permission denied: `open` needs the fs-read capability"},
+		{`removeFile(outside + "/out.txt")`, nil, "permission denied: `removeFile` cannot access " + outside + "/out.txt outside of the allowed directories"},
+		{`remove([1, 2], 0)`, object.Capabilities, "[2]"},
+		{`args()`, nil, "[a, b]"},
+		{`args()`, object.Capabilities, "[a, b]"},
+		{`now() > 0`, nil, "true"},
+		{`sleep(1)`, []object.Capability{object.TIME_CAP}, "permission denied: `sleep` needs the time capability"},
+		{`rand(10) < 10`, nil, "true"},
+		{`rand()`, []object.Capability{object.RANDOM_CAP}, "permission denied: `rand` needs the random capability"},
+	}
+
+	for _, tt := range tests {
+		runtime := &object.Runtime{
+			Streams:     object.NewStreams(strings.NewReader(""), io.Discard, io.Discard),
+			Permissions: object.Permissions{Roots: []string{root}},
+			Args:        []string{"a", "b"},
+		}
+		runtime.Permissions.Deny(tt.denied...)
+		defer runtime.CloseFiles()
+
+		env := object.NewEnvironmentWithRuntime(runtime)
+		env.Set("root", &object.String{Value: root})
+		env.Set("outside", &object.String{Value: outside})
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+
+		result := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			result = errObj.Message
+		}
+		if result != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { throw "oops"; 1 } catch (e) { e["message"] }`, "oops"},
+		{`try { throw "oops" } catch (e) { e["kind"] }`, "Error"},
+		{`try { throw [1, 2] } catch (e) { e["value"] }`, "[1, 2]"},
+		{`try { 1 / 0 } catch (e) { e["kind"] + ": " + e["message"] }`, "RuntimeError: division by zero"},
+		{`try { missing } catch (e) { e["kind"] }`, "NameError"},
+		{`try { first(1) } catch (e) { e["message"] }`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`try { map([1], fn(x) { throw "in callback" }) } catch (e) { e["message"] }`, "in callback"},
+		{`try { throw "a" } catch { 5 }`, 5},
+		{`let x = 0; try { x = 1 } finally { x = x + 10 }; x`, 11},
+		{`let x = 0; try { throw "a" } catch (e) { x = 1 } finally { x = x + 10 }; x`, 11},
+		{`try { 1 } finally { 2 }`, 1},
+		{`let f = fn() { try { return 1 } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`try { try { throw "inner" } finally { 1 } } catch (e) { e["message"] }`, "inner"},
+		{`try { try { throw "a" } catch (e) { throw e } } catch (e) { e["line"] }`, 1},
+		{`try { throw "a" } catch (e) { throw "b" }`, "b"},
+		{`try { throw "a" } finally { 1 }`, "a"},
+		{`let e = try { throw "a" } catch (e) { e }; e`, "Error: a"},
+		// The parameter is only bound in the catch block
+		{`const e = 1; try { throw "a" } catch (e) { 2 }; e`, 1},
+		{`let e = 1; try { throw "a" } catch (e) { e = 2 }; e`, 1},
+		{`try { throw "a" } catch (e) { 1 }; e`, "identifier not found: e"},
+		{`let n = 0; try { throw "a" } catch (e) { n = 2 }; n`, 2},
+		{`try { throw "a" } catch (e) { let m = e["message"] }; m`, "a"},
+		{`let f = fn() { throw "deep" }; let g = fn() { f() };
+try { g() } catch (e) { e["stack"] }`, "[f (line 1, column 47), g (line 2, column 7)]"},
+		{`try {
+  1 + true
+} catch (e) { [e["line"], e["column"]] }`, "[2, 5]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			result := evaluated.Inspect()
+			if errObj, ok := evaluated.(*object.Error); ok {
+				result = errObj.Message
+			}
+			if result != expected {
+				t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, expected, result)
+			}
+		}
+	}
+}
+
+func TestErrorValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`error("bad")`, "Error: bad"},
+		{`error("bad", "ParseError")["kind"]`, "ParseError"},
+		{`isError(error("bad"))`, "true"},
+		{`isError(1)`, "false"},
+		{`let f = fn() { error("bad")?; 1 }; f()`, "Error: bad"},
+		{`let f = fn() { let x = 2?; x + 1 }; f()`, "3"},
+		{`let f = fn(p) { let [_, err] = tryCall(readFile, p); err?; "unreachable" }; let r = f("/missing/file"); [isError(r), r["kind"]]`, "[true, IOError]"},
+		{`let f = fn() { 1 / 0? }; f()`, "ERROR: division by zero"},
+		// Raised errors are not turned into values
+		{`let f = fn(p) { readFile(p)?; "unreachable" }; try { f("/missing/file") } catch (e) { e["kind"] }`, "IOError"},
+		{`let f = fn() { (1 / 0)? }; f()`, "ERROR: division by zero"},
+		// Outside of functions, ? raises the error
+		{`error("top")?; "unreachable"`, "ERROR: top"},
+		{`try { error("top", "Custom")? } catch (e) { e["kind"] }`, "Custom"},
+		{`readFile("/missing/file")?; "unreachable"`, "ERROR: error on read file, open /missing/file: no such file or directory"},
+		{`2?`, "2"},
+		{`let parse = fn(s) { if (isDigit(s)) { ord(s) - 48 } else { error("not a digit: " + s) } };
+let sum = fn(a, b) { parse(a)? + parse(b)? };
+[sum("1", "2"), sum("1", "x")]`, "[3, Error: not a digit: x]"},
+		{`tryCall(fn(x) { x * 2 }, 21)`, "[42, null]"},
+		{`let r = tryCall(readFile, "/missing/file"); [r[0], r[1]["kind"]]`, "[null, IOError]"},
+		{`tryCall(fn() { error("e")? })`, "[null, Error: e]"},
+		{`let f = fn() { f() }; tryCall(f)`, "ERROR: maximum call depth of 10000 exceeded"},
+		{`try { throw error("thrown", "Custom") } catch (e) { e["kind"] }`, "Custom"},
+		{`error(1)`, "ERROR: argument to `error` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestErrorReport(t *testing.T) {
+	input := `let f = fn(x) {
+  10 / x
+};
+let g = fn() { f(0) };
+g()`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := `line 2, column 6: RuntimeError: division by zero
+	at f (line 4, column 16)
+	at g (line 5, column 1)`
+	if errObj.Report() != expected {
+		t.Errorf("wrong report. want=%q, got=%q", expected, errObj.Report())
+	}
+}
+
+func TestConstAndFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`const x = 5; x * 2`, "10"},
+		{`const x = 5; let f = fn() { x }; f()`, "5"},
+		{`const x = 5; let f = fn(x) { x = x + 1; x }; f(1)`, "2"},
+		{`const xs = [1, 2]; xs[0] = 5; xs`, "[5, 2]"},
+		{`let a = freeze([1, [2]]); a[0] = 5`, "ERROR: cannot modify frozen ARRAY"},
+		{`let a = freeze([1, [2]]); a[1][0] = 5`, "ERROR: cannot modify frozen ARRAY"},
+		{`let h = freeze({"a": {"b": 1}}); h["a"]["b"] = 2`, "ERROR: cannot modify frozen HASH"},
+		{`let h = freeze({"a": 1}); h["c"] = 2`, "ERROR: cannot modify frozen HASH"},
+		{`let h = freeze({"a": 1}); delete(h, "a")`, "ERROR: cannot modify frozen HASH"},
+		{`let a = freeze([1, 2]); [push(a, 3), pop(a), remove(a, 0), a]`, "[[1, 2, 3], [1], [2], [1, 2]]"},
+		{`let a = freeze([1]); let b = push(a, 2); b[0] = 5; [a, b, isFrozen(a), isFrozen(b)]`, "[[1], [5, 2], true, false]"},
+		{`freeze(1)`, "1"},
+		{`let h = freeze({"a": [1]}); h["a"] == [1]`, "true"},
+		{`try { const c = 1; c = 2 } catch (e) { e["message"] }`, "cannot assign to constant c"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	// Constants declared by earlier programs, like previous lines of the
+	// REPL, are only known at runtime.
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New(`const limit = 3`)).ParseProgram(), env)
+
+	runtimeTests := []struct {
+		input    string
+		expected string
+	}{
+		{`limit = 4`, "ERROR: cannot assign to constant limit"},
+		{`let limit = 4`, "ERROR: cannot redeclare constant limit"},
+		{`let f = fn() { limit = 4 }; f()`, "ERROR: cannot assign to constant limit"},
+		{`let f = fn() { let limit = 4; limit }; f()`, "4"},
+		{`limit`, "3"},
+	}
+
+	for _, tt := range runtimeTests {
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let [x, y] = [1, 2]; x + y`, "3"},
+		{`let [head, ...tail] = [1, 2, 3]; [head, tail]`, "[1, [2, 3]]"},
+		{`let [a, ...rest] = [1]; rest`, "[]"},
+		{`let [a, b, c] = [1, 2]; c`, "null"},
+		{`let [[a, b], c] = [[1, 2], 3]; [a, b, c]`, "[1, 2, 3]"},
+		{`let {name, age} = {"name": "Ada", "age": 36}; name + " " + str(age)`, "Ada 36"},
+		{`let {"pos": [x, y], missing} = {"pos": [3, 4]}; [x, y, missing]`, "[3, 4, null]"},
+		{`let a = 1; let b = 2; [a, b] = [b, a]; [a, b]`, "[2, 1]"},
+		{`let xs = [1, 2]; [xs[0], xs[1]] = [xs[1], xs[0]]; xs`, "[2, 1]"},
+		{`let xs = [1, 2]; let x = 0; {x} = {"x": 5}; x`, "5"},
+		{`x := 5; x * 2`, "10"},
+		{`[q, r] := [7 / 2, 7 % 2]; [q, r]`, "[3, 1]"},
+		{`let dist = fn([ax, ay], [bx, by]) { (bx - ax) * (by - ay) }; dist([1, 2], [4, 6])`, "12"},
+		{`let greet = fn({name}) { "hi " + name }; greet({"name": "Bob"})`, "hi Bob"},
+		{`const [a, b] = [1, 2]; a = 3`, "ERROR: cannot assign to constant a"},
+		{`let [x, y] = 5`, "ERROR: cannot destructure INTEGER as ARRAY"},
+		{`let {x} = [1]`, "ERROR: cannot destructure ARRAY as HASH"},
+		{`let f = fn([x]) { x }; f(1)`, "ERROR: cannot destructure INTEGER as ARRAY"},
+		{`[a, b] = [1, 2]`, "ERROR: identifier not found: a"},
+		{`let a = freeze([1, 2]); [a[0], a[1]] = [3, 4]`, "ERROR: cannot modify frozen ARRAY"},
+		{`[...[1, 2]]`, "ERROR: unexpected ... outside of a destructuring pattern"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match (2) { 1 => "one", 2 => "two", _ => "many" }`, "two"},
+		{`match (7) { 1 => "one", 2 => "two", _ => "many" }`, "many"},
+		{`match (7) { 1 => "one", 2 => "two" }`, "null"},
+		{`match ("b") { "a" | "b" => 1, _ => 2 }`, "1"},
+		{`match (-3) { -3 => "minus three", _ => "other" }`, "minus three"},
+		{`match (1.5) { 1.5 => "float", _ => "other" }`, "float"},
+		{`let grade = fn(n) { match (n) { 90..=100 => "A", 80..90 => "B", _ => "C" } }; [grade(100), grade(85), grade(90), grade(12)]`, "[A, B, A, C]"},
+		{`match ('q') { 'a'..='z' => "lower", 'A'..='Z' => "upper", _ => "other" }`, "lower"},
+		{`match ("x") { 1..5 => "small", _ => "other" }`, "other"},
+		{`match ([1, 2]) { [] => 0, [x] => x, [x, y] => x + y, _ => -1 }`, "3"},
+		{`match ([1, 2, 3]) { [] => [], [0, ...rest] => rest, [first, ...rest] => [first, rest] }`, "[1, [2, 3]]"},
+		{`match ([1, 2, 3]) { [0, ...rest] => rest, [first, ...rest] => [first, rest] }`, "ERROR: non-exhaustive match on ARRAY: arrays of length 0 are not covered"},
+		{`match ([[1, 2], 3]) { [[a, b], c] => a + b + c, _ => 0 }`, "6"},
+		{`let area = fn(shape) { match (shape) { {"kind": "square", side} => side * side, {"kind": "rect", "w": w, "h": h} => w * h, _ => 0 } }; [area({"kind": "square", "side": 3}), area({"kind": "rect", "w": 2, "h": 5}), area({"kind": "circle"})]`, "[9, 10, 0]"},
+		{`match ({"a": 1}) { {b} => b, _ => "no b" }`, "no b"},
+		{`match (5) { n if n < 0 => "negative", n if n > 0 => "positive", _ => "zero" }`, "positive"},
+		{`match (5) { n => n * 2 }`, "10"},
+		{`match (5) { n => { let m = n + 1; m * 2 } }`, "12"},
+		{`let f = fn(x) { match (x) { 0 => { return "early" } _ => "late" }; "after" }; [f(0), f(1)]`, "[early, after]"},
+		{`match (true) { true => 1, false => 0 }`, "1"},
+		{`match ([]) { [] => "empty", [_, ...rest] => "some" }`, "empty"},
+		{`match ("s") { true => 1, false => 0 }`, "null"},
+		{`match (true) { true => 1 }`, "ERROR: non-exhaustive match on BOOLEAN: false is not covered"},
+		{`match ([1]) { [] => 0, [x, y, ...r] => 2 }`, "ERROR: non-exhaustive match on ARRAY: arrays of length 1 are not covered"},
+		{`match ([1]) { [] => 0, [1] => 1 }`, "ERROR: non-exhaustive match on ARRAY: arrays of length 1 are not covered"},
+		{`match (1) { x if x > 5 => 0, true => 1 }`, "null"},
+		{`match (1 / 0) { _ => 0 }`, "ERROR: division by zero"},
+		// Bindings are scoped to their arm
+		{`let x = 10; [match (3) { x => x * 2 }, x]`, "[6, 10]"},
+		{`match (5) { n if n > 9 => 2, _ => 3 }; n`, "ERROR: identifier not found: n"},
+		{`match ([1, 2]) { [a, b] => a + b, _ => 0 }; a`, "ERROR: identifier not found: a"},
+		{`const x = 1; match (2) { x => x * 10 }`, "20"},
+		// Other names are assigned and declared in the enclosing scope
+		{`let y = 0; match (1) { 1 => y = 5, _ => 0 }; y`, "5"},
+		{`let y = 0; match ([2]) { [x] => { y = x * 3 }, _ => 0 }; y`, "6"},
+		{`let x = 1; match (2) { x => { x = 7 } }; x`, "1"},
+		{`match (1) { _ => { let z = 4 } }; z`, "4"},
+		{`match(regex("a+"), "caab")`, "[aa]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = 10; x += 5; x`, "15"},
+		{`let x = 10; x -= 5`, "5"},
+		{`let x = 10; x *= 2; x /= 4; x`, "5"},
+		{`let x = 10; x %= 4; x`, "2"},
+		{`let x = 3; x **= 3; x`, "27"},
+		{`let x = 12; x &= 10; x`, "8"},
+		{`let x = 12; x |= 3; x`, "15"},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{`let f = 1.5; f *= 2; f`, "3.000000"},
+		{`2 ** 10`, "1024"},
+		{`2 ** -1`, "0.500000"},
+		{`2.0 ** 0.5 > 1.41`, "true"},
+		{`let i = 0; [i++, i, ++i, i, i--, --i]`, "[0, 1, 2, 2, 2, 0]"},
+		{`let c = 'a'; c++; c`, "b"},
+		{`let sum = 0; for (let i = 0; i < 5; i++) { sum += i }; sum`, "10"},
+		{`let counts = {"a": 1}; counts["a"] += 1; counts["a"]++; counts["a"]`, "3"},
+		{`let a = [1, 2]; a[1] *= 10; a[0]--; a`, "[0, 20]"},
+		{`let calls = [0]; let a = [0, 0]; let k = fn() { calls[0]++; 1 }; a[k()] += 5; a[k()]++; [a, calls[0]]`, "[[0, 6], 2]"},
+		{`let x = 1; x += "a"`, "ERROR: type mismatch: INTEGER + STRING"},
+		{`let s = "a"; s++`, "ERROR: unknown operator: ++STRING"},
+		{`y += 1`, "ERROR: identifier not found: y"},
+		{`let a = [1]; a[1] += 1`, "ERROR: index 1 out of bounds in array of length 1"},
+		{`let a = [1]; a[5] = 1`, "ERROR: index 5 out of bounds in array of length 1"},
+		{`let h = {}; h["a"] += 1`, "ERROR: type mismatch: NULL + INTEGER"},
+		{`let a = freeze([1]); a[0] += 1`, "ERROR: cannot modify frozen ARRAY"},
+		{`let a = freeze([1]); a[0]++`, "ERROR: cannot modify frozen ARRAY"},
+		{`let x = 1; x /= 0`, "ERROR: division by zero"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New(`const limit = 3`)).ParseProgram(), env)
+	for _, input := range []string{`limit += 1`, `limit++`} {
+		evaluated := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+		if evaluated.Inspect() != "ERROR: cannot assign to constant limit" {
+			t.Errorf("wrong result for %q. got=%q", input, evaluated.Inspect())
+		}
+	}
+}
+
 func TestErrorHandling(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -669,6 +1677,38 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			`1 / 0`,
+			"division by zero",
+		},
+		{
+			`5 % 0`,
+			"division by zero",
+		},
+		{
+			`for (true) { 1 + true; }`,
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			`{[1, fn(x) { x }]: 1}`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			`{}[[{}]]`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			`{0.0 / 0.0: 1}`,
+			"unusable as hash key: FLOAT",
+		},
+		{
+			`let h = {}; h[[0.0 / 0.0]] = 1`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			`let a = [1]; a[0] = a; {a: 1}`,
+			"unusable as hash key: ARRAY",
+		},
 		{
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
@@ -777,6 +1817,82 @@ func TestFunctionObject(t *testing.T) {
 	}
 }
 
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fn double(x) { x * 2 } double(21)`, "42"},
+		{`let r = square(4); fn square(x) { x * x }; r`, "16"},
+		{`fn isEven(n) { n == 0 ? true : isOdd(n - 1) } fn isOdd(n) { n == 0 ? false : isEven(n - 1) } [isEven(10), isOdd(7), isEven(3)]`, "[true, true, false]"},
+		{`fn fact(n) { if (n < 2) { return 1 }; n * fact(n - 1) } fact(10)`, "3628800"},
+		{`fn outer() { let r = inner(); fn inner() { 5 } r } outer()`, "5"},
+		{`fn outer() { fn inner() { 5 } inner } outer()()`, "5"},
+		{`fn outer() { fn inner() { 5 } 1 } outer(); inner`, "ERROR: identifier not found: inner"},
+		{`fn(x) { x }(3)`, "3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	// Constants declared by earlier programs are only known at runtime
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New(`const f = 1`)).ParseProgram(), env)
+	evaluated := Eval(parser.New(lexer.New(`fn f() { 2 }`)).ParseProgram(), env)
+	if evaluated == nil || evaluated.Inspect() != "ERROR: cannot redeclare constant f" {
+		t.Errorf("wrong result redeclaring a constant. got=%v", evaluated)
+	}
+
+	fn, ok := testEval(`fn add(a, b) { a + b }; add`).(*object.Function)
+	if !ok || fn.Name != "add" || !strings.HasPrefix(fn.Inspect(), "fn add(a, b) {") {
+		t.Errorf("wrong declared function. got=%v", fn)
+	}
+
+	input := `fn check(x) {
+  if (x < 0) { throw "negative" }
+  x
+}
+let fns = [check];
+fns[0](-1)`
+
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+	expected := `line 2, column 16: Error: negative
+	at check (line 6, column 4)`
+	if errObj.Report() != expected {
+		t.Errorf("wrong report. want=%q, got=%q", expected, errObj.Report())
+	}
+}
+
+func TestArrowFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let double = (x) => x * 2; double(21)`, "42"},
+		{`map([1, 2, 3], (x) => x * x)`, "[1, 4, 9]"},
+		{`reduce([1, 2, 3], (acc, x) => acc + x, 0)`, "6"},
+		{`let adder = (x) => (y) => x + y; adder(2)(3)`, "5"},
+		{`let k = () => 7; k()`, "7"},
+		{`map([[1, 2], [3, 4]], ([a, b]) => a * b)`, "[2, 12]"},
+		{`sort([3, 1, 2], (a, b) => a > b ? -1 : 1)`, "[3, 2, 1]"},
+		{`let f = (x) => x; f()`, "ERROR: wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 let newAdder = fn(x) {
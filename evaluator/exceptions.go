@@ -0,0 +1,161 @@
+package evaluator
+
+import (
+	"Nutlang/ast"
+	"Nutlang/object"
+	"Nutlang/token"
+	"fmt"
+)
+
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Body, env)
+
+	if err, ok := result.(*object.Error); ok && te.Catch != nil && catchable(err) {
+		// The parameter is only visible in the catch block
+		bindings := map[string]object.Object{}
+		if te.Param != nil {
+			bindings[te.Param.Value] = &object.Exception{Error: err}
+		}
+		result = Eval(te.Catch, object.NewBindingEnvironment(env, bindings))
+	}
+
+	if te.Finally != nil {
+		// An error or return in the finally clause replaces the result
+		if final := Eval(te.Finally, env); isAbrupt(final) {
+			return final
+		}
+	}
+
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// catchable reports whether try and catch may handle err. Limit errors
+// always stop the program, so that scripts cannot escape their limits
+// by catching them.
+func catchable(err *object.Error) bool {
+	return err.Kind != object.LIMIT_ERROR
+}
+
+// evalPropagateExpression evaluates x?, which returns x from the
+// enclosing function when it is an error value. Outside of functions the
+// error is raised instead. Errors raised evaluating x keep propagating as
+// they are.
+func evalPropagateExpression(node *ast.PostfixExpression, env *object.Environment) object.Object {
+	val := Eval(node.Left, env)
+
+	exception, ok := val.(*object.Exception)
+	if !ok {
+		return val
+	}
+	if env.Runtime().CallDepth == 0 {
+		return throwValue(exception)
+	}
+	return &object.ReturnValue{Value: exception}
+}
+
+// throwValue returns the error raised by throwing val. Throwing a caught
+// exception raises it again as it was.
+func throwValue(val object.Object) *object.Error {
+	switch val := val.(type) {
+	case *object.Exception:
+		return val.Error
+	case *object.String:
+		return &object.Error{Message: val.Value, Kind: object.ERROR, Value: val}
+	default:
+		return &object.Error{Message: val.Inspect(), Kind: object.ERROR, Value: val}
+	}
+}
+
+func evalExceptionIndexExpression(exception, index object.Object) object.Object {
+	err := exception.(*object.Exception).Error
+
+	switch index.(*object.String).Value {
+	case "message":
+		return &object.String{Value: err.Message}
+	case "kind":
+		return &object.String{Value: err.Kind}
+	case "stack":
+		return stringsToArray(err.Stack)
+	case "line":
+		return &object.Integer{Value: int64(err.Line)}
+	case "column":
+		return &object.Integer{Value: int64(err.Column)}
+	case "value":
+		if err.Value == nil {
+			return NULL
+		}
+		return err.Value
+	default:
+		return NULL
+	}
+}
+
+// locateError records in err the position of node, where it was raised,
+// and the calls in progress.
+func locateError(err *object.Error, node ast.Node, env *object.Environment) {
+	tok := nodeToken(node)
+	if tok.Line == 0 {
+		return
+	}
+	err.Line, err.Column = tok.Line, tok.Column
+
+	frames := env.Runtime().Frames
+	err.Stack = make([]string, len(frames))
+	for i, frame := range frames {
+		err.Stack[len(frames)-1-i] = frame
+	}
+}
+
+// callFrame describes a call of function for error stacks, naming it
+// after its declaration or else the variable it is called through.
+func callFrame(node *ast.CallExpression, function object.Object) string {
+	name := "fn"
+	if fn, ok := function.(*object.Function); ok && fn.Name != "" {
+		name = fn.Name
+	} else if ident, ok := node.Function.(*ast.Identifier); ok {
+		name = ident.Value
+	}
+
+	tok := nodeToken(node)
+	return fmt.Sprintf("%s (line %d, column %d)", name, tok.Line, tok.Column)
+}
+
+// nodeToken returns the token locating node in the source.
+func nodeToken(node ast.Node) token.Token {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		return nodeToken(node.Function)
+	case *ast.Identifier:
+		return node.Token
+	case *ast.InfixExpression:
+		return node.Token
+	case *ast.PostfixExpression:
+		return node.Token
+	case *ast.PrefixExpression:
+		return node.Token
+	case *ast.IndexExpression:
+		return node.Token
+	case *ast.AssignmentExpression:
+		return node.Token
+	case *ast.LetStatement:
+		return node.Token
+	case *ast.ReturnStatement:
+		return node.Token
+	case *ast.ThrowStatement:
+		return node.Token
+	case *ast.ExpressionStatement:
+		return node.Token
+	case *ast.ArrayLiteral:
+		return node.Token
+	case *ast.HashLiteral:
+		return node.Token
+	case *ast.InterpolatedString:
+		return node.Token
+	case *ast.FunctionLiteral:
+		return node.Token
+	}
+	return token.Token{}
+}
@@ -0,0 +1,135 @@
+package evaluator
+
+import (
+	"Nutlang/object"
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// openModes maps the modes accepted by `open` to os.OpenFile flags.
+var openModes = map[string]int{
+	"r":  os.O_RDONLY,
+	"r+": os.O_RDWR,
+	"w":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	"w+": os.O_RDWR | os.O_CREATE | os.O_TRUNC,
+	"a":  os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	"a+": os.O_RDWR | os.O_CREATE | os.O_APPEND,
+}
+
+// openFile opens path for the builtin name. The file is tracked by the
+// runtime until closed, so that Runtime.CloseFiles can release it when
+// the program exits.
+func openFile(ctx object.Context, name, path, mode string) object.Object {
+	flag, ok := openModes[mode]
+	if !ok {
+		return newError("unknown file mode %q", mode)
+	}
+
+	var caps []object.Capability
+	if mode[0] == 'r' || strings.HasSuffix(mode, "+") {
+		caps = append(caps, object.FS_READ_CAP)
+	}
+	if mode != "r" {
+		caps = append(caps, object.FS_WRITE_CAP)
+	}
+	if err := permitPath(ctx, name, path, caps...); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return ioError(name, err)
+	}
+
+	fd := object.NewFileDescriptor(f)
+	rt := ctx.Runtime()
+	if rt.Files == nil {
+		rt.Files = map[*object.FileDescriptor]struct{}{}
+	}
+	rt.Files[fd] = struct{}{}
+	return fd
+}
+
+func closeFile(ctx object.Context, fd *object.FileDescriptor) object.Object {
+	if fd.Closed {
+		return newError("file %s already closed", fd.Value.Name())
+	}
+
+	delete(ctx.Runtime().Files, fd)
+
+	fd.Closed = true
+	if err := fd.Value.Close(); err != nil {
+		return ioError("close", err)
+	}
+	return NULL
+}
+
+// writeFile writes s to fd where reading stopped. The input buffered
+// ahead of that position is dropped, so that it is read again after the
+// write rather than from before it.
+func writeFile(fd *object.FileDescriptor, s string) (int, error) {
+	if buffered := fd.Reader.Buffered(); buffered > 0 {
+		if _, err := fd.Value.Seek(int64(-buffered), io.SeekCurrent); err != nil {
+			return 0, err
+		}
+	}
+	fd.Reader.Reset(fd.Value)
+	return io.WriteString(fd.Value, s)
+}
+
+// readLine returns the next line of r without its line ending, or NULL
+// at the end of input.
+func readLine(name string, r *bufio.Reader) object.Object {
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return ioError(name, err)
+	}
+	if err == io.EOF && line == "" {
+		return NULL
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return &object.String{Value: line}
+}
+
+// fileArg returns the i-th argument as an open file descriptor.
+func fileArg(name string, args []object.Object, i int) (*object.FileDescriptor, *object.Error) {
+	fd, ok := args[i].(*object.FileDescriptor)
+	if !ok {
+		return nil, newError("%s to `%s` must be FD, got %s",
+			argumentName(args, i), name, args[i].Type())
+	}
+	if fd.Closed {
+		return nil, newError("file %s already closed", fd.Value.Name())
+	}
+	return fd, nil
+}
+
+// lineIterator lazily yields the lines of fd, closing it once the last
+// line has been read or reading fails.
+func lineIterator(ctx object.Context, fd *object.FileDescriptor) *object.Iterator {
+	return &object.Iterator{
+		Next: func() (object.Object, bool) {
+			if fd.Closed {
+				return nil, false
+			}
+
+			line := readLine("lines", fd.Reader)
+			if line == NULL {
+				closeFile(ctx, fd)
+				return nil, false
+			}
+			if isError(line) {
+				closeFile(ctx, fd)
+			}
+			return line, true
+		},
+	}
+}
+
+func ioError(name string, err error) *object.Error {
+	return newKindError(object.IO_ERROR, "error in `%s`: %s", name, err)
+}
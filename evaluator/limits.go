@@ -0,0 +1,114 @@
+package evaluator
+
+import (
+	"Nutlang/object"
+)
+
+// DefaultMaxCallDepth is the call depth limit of programs without one,
+// which keeps deep recursion from overflowing the Go stack.
+const DefaultMaxCallDepth = 10000
+
+// cancelCheckInterval is the number of steps between two checks of the
+// program's context, which are relatively expensive.
+const cancelCheckInterval = 256
+
+// step counts one evaluation step of the program running in env.
+func step(env *object.Environment) *object.Error {
+	rt := env.Runtime()
+	rt.Steps++
+
+	if rt.Exhausted != "" {
+		return newKindError(object.LIMIT_ERROR, "%s", rt.Exhausted)
+	}
+	if rt.Limits.MaxSteps > 0 && rt.Steps > rt.Limits.MaxSteps {
+		return limitError(rt, "step limit of %d exceeded", rt.Limits.MaxSteps)
+	}
+	if rt.Steps%cancelCheckInterval == 0 {
+		return checkCancelled(rt)
+	}
+	return nil
+}
+
+// checkCancelled returns a limit error once the context of the program
+// running with rt is done.
+func checkCancelled(rt *object.Runtime) *object.Error {
+	if ctx := rt.Limits.Context; ctx != nil {
+		if err := ctx.Err(); err != nil {
+			return limitError(rt, "execution cancelled: %s", err)
+		}
+	}
+	return nil
+}
+
+// tick is called by builtins on the i-th iteration of a loop that may
+// run long, and returns a limit error once the program is cancelled.
+func tick(ctx object.Context, i int64) *object.Error {
+	if i%cancelCheckInterval != 0 {
+		return nil
+	}
+	return checkCancelled(ctx.Runtime())
+}
+
+// limitError returns the limit error stopping the program running with
+// rt, which stays exhausted until it is reset.
+func limitError(rt *object.Runtime, format string, a ...interface{}) *object.Error {
+	err := newKindError(object.LIMIT_ERROR, format, a...)
+	rt.Exhausted = err.Message
+	return err
+}
+
+// enterCall counts a function call until the matching leaveCall.
+func enterCall(env *object.Environment) *object.Error {
+	rt := env.Runtime()
+
+	limit := rt.Limits.MaxCallDepth
+	if limit <= 0 {
+		limit = DefaultMaxCallDepth
+	}
+	if rt.CallDepth >= limit {
+		return limitError(rt, "maximum call depth of %d exceeded", limit)
+	}
+
+	rt.CallDepth++
+	return nil
+}
+
+func leaveCall(env *object.Environment) {
+	env.Runtime().CallDepth--
+}
+
+// allocated counts the memory of obj, newly created by the program
+// running in env, and returns obj unless the allocation limit is
+// exceeded.
+func allocated(env *object.Environment, obj object.Object) object.Object {
+	var size int64
+	switch obj := obj.(type) {
+	case *object.Array:
+		size = int64(len(obj.Elements))
+	case *object.Hash:
+		size = int64(obj.Len())
+	case *object.String:
+		size = int64(len(obj.Value))
+	default:
+		return obj
+	}
+
+	rt := env.Runtime()
+	if err := checkAllocation(rt, size); err != nil {
+		return err
+	}
+	rt.Allocations += size
+	return obj
+}
+
+// checkAllocation returns a limit error if the program running with rt
+// cannot allocate size more array elements, hash entries or string
+// bytes. Builtins check the size of large values before building them,
+// and they are counted by allocated once returned.
+func checkAllocation(rt *object.Runtime, size int64) *object.Error {
+	limit := rt.Limits.MaxAllocations
+	if limit > 0 && size > limit-rt.Allocations {
+		return limitError(rt, "allocation limit of %d exceeded", limit)
+	}
+	return nil
+}
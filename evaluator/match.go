@@ -0,0 +1,253 @@
+package evaluator
+
+import (
+	"Nutlang/ast"
+	"Nutlang/object"
+)
+
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	if err := checkExhaustive(me); err != nil {
+		return err
+	}
+
+	val := Eval(me.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	for _, arm := range me.Arms {
+		bindings := map[string]object.Object{}
+		matched, err := matchPattern(arm.Pattern, val, bindings, env)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		// The bindings of an arm are only visible in its guard and
+		// result, which otherwise run in env
+		armEnv := object.NewBindingEnvironment(env, bindings)
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isAbrupt(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		result := Eval(arm.Result, armEnv)
+		if result == nil {
+			return NULL
+		}
+		return result
+	}
+
+	return NULL
+}
+
+// matchPattern reports whether val matches pattern, collecting the values
+// of the identifiers it binds in bindings. The error is an error raised
+// evaluating a part of the pattern, or nil.
+func matchPattern(
+	pattern ast.Expression,
+	val object.Object,
+	bindings map[string]object.Object,
+	env *object.Environment,
+) (bool, object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			bindings[pattern.Value] = val
+		}
+		return true, nil
+
+	case *ast.AlternativePattern:
+		for _, alternative := range pattern.Patterns {
+			matched, err := matchPattern(alternative, val, bindings, env)
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+
+	case *ast.RangePattern:
+		low := Eval(pattern.Low, env)
+		if isAbrupt(low) {
+			return false, low
+		}
+		high := Eval(pattern.High, env)
+		if isAbrupt(high) {
+			return false, high
+		}
+
+		operator := "<"
+		if pattern.Inclusive {
+			operator = "<="
+		}
+		// Values that cannot be compared with the bounds are not in range
+		return evalInfixExpression(">=", val, low) == TRUE &&
+			evalInfixExpression(operator, val, high) == TRUE, nil
+
+	case *ast.ArrayLiteral:
+		array, ok := val.(*object.Array)
+		if !ok {
+			return false, nil
+		}
+
+		elements := pattern.Elements
+		var rest *ast.SpreadElement
+		if n := len(elements); n > 0 {
+			if spread, ok := elements[n-1].(*ast.SpreadElement); ok {
+				rest = spread
+				elements = elements[:n-1]
+			}
+		}
+		if len(array.Elements) < len(elements) || (rest == nil && len(array.Elements) != len(elements)) {
+			return false, nil
+		}
+
+		for i, elem := range elements {
+			matched, err := matchPattern(elem, array.Elements[i], bindings, env)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		if rest != nil {
+			tail := append([]object.Object{}, array.Elements[len(elements):]...)
+			return matchPattern(rest.Value, &object.Array{Elements: tail}, bindings, env)
+		}
+		return true, nil
+
+	case *ast.HashLiteral:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return false, nil
+		}
+
+		for _, keyNode := range pattern.Keys {
+			key := Eval(keyNode, env)
+			if isAbrupt(key) {
+				return false, key
+			}
+			item, ok := hash.Get(key)
+			if !ok {
+				return false, nil
+			}
+			matched, err := matchPattern(pattern.Pairs[keyNode], item, bindings, env)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	// Any other pattern is a literal
+	literal := Eval(pattern, env)
+	if isAbrupt(literal) {
+		return false, literal
+	}
+	return object.Equals(literal, val), nil
+}
+
+// checkExhaustive returns an error when all the patterns of a match
+// without a catch-all arm are booleans, or all are arrays, and the arms
+// do not cover every boolean or every array length. Otherwise the type of
+// the value is not known and the match evaluates to null when no arm
+// matches.
+func checkExhaustive(me *ast.MatchExpression) *object.Error {
+	var kind object.ObjectType
+	var patterns []ast.Expression
+
+	for _, arm := range me.Arms {
+		for _, pattern := range alternatives(arm.Pattern) {
+			var patternKind object.ObjectType
+			switch pattern.(type) {
+			case *ast.Identifier:
+				// A catch-all, or a guarded binding accepting any type
+				return nil
+			case *ast.Boolean:
+				patternKind = object.BOOLEAN_OBJ
+			case *ast.ArrayLiteral:
+				patternKind = object.ARRAY_OBJ
+			default:
+				return nil
+			}
+
+			if kind != "" && kind != patternKind {
+				return nil
+			}
+			kind = patternKind
+			if arm.Guard == nil {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+
+	switch kind {
+	case object.BOOLEAN_OBJ:
+		covered := map[bool]bool{}
+		for _, pattern := range patterns {
+			covered[pattern.(*ast.Boolean).Value] = true
+		}
+		for _, value := range []bool{true, false} {
+			if !covered[value] {
+				return newError("non-exhaustive match on BOOLEAN: %t is not covered", value)
+			}
+		}
+
+	case object.ARRAY_OBJ:
+		// Arrays of elements that are all bindings cover either one
+		// length, or with a rest element all lengths from theirs.
+		lengths := map[int]bool{}
+		minRest := -1
+		for _, pattern := range patterns {
+			elements := pattern.(*ast.ArrayLiteral).Elements
+			n := len(elements)
+			hasRest := n > 0 && isSpread(elements[n-1])
+			if hasRest {
+				n--
+			}
+			if !allBindings(elements[:n]) {
+				continue
+			}
+			if !hasRest {
+				lengths[n] = true
+			} else if minRest == -1 || n < minRest {
+				minRest = n
+			}
+		}
+
+		for n := 0; minRest == -1 || n < minRest; n++ {
+			if !lengths[n] {
+				return newError("non-exhaustive match on ARRAY: arrays of length %d are not covered", n)
+			}
+		}
+	}
+
+	return nil
+}
+
+func alternatives(pattern ast.Expression) []ast.Expression {
+	if alt, ok := pattern.(*ast.AlternativePattern); ok {
+		return alt.Patterns
+	}
+	return []ast.Expression{pattern}
+}
+
+func isSpread(exp ast.Expression) bool {
+	_, ok := exp.(*ast.SpreadElement)
+	return ok
+}
+
+func allBindings(patterns []ast.Expression) bool {
+	for _, pattern := range patterns {
+		if _, ok := pattern.(*ast.Identifier); !ok {
+			return false
+		}
+	}
+	return true
+}
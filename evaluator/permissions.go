@@ -0,0 +1,80 @@
+package evaluator
+
+import (
+	"Nutlang/object"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// permit returns a permission denied error unless the program calling
+// the builtin name may use capability c.
+func permit(ctx object.Context, name string, c object.Capability) *object.Error {
+	if !ctx.Runtime().Permissions.Allows(c) {
+		return newKindError(object.PERMISSION_ERROR,
+			"permission denied: `%s` needs the %s capability", name, c)
+	}
+	return nil
+}
+
+// permitPath is like permit for a builtin accessing path, which must
+// also be inside one of the allowed filesystem roots.
+func permitPath(ctx object.Context, name string, path string, caps ...object.Capability) *object.Error {
+	for _, c := range caps {
+		if err := permit(ctx, name, c); err != nil {
+			return err
+		}
+	}
+
+	roots := ctx.Runtime().Permissions.Roots
+	if len(roots) == 0 {
+		return nil
+	}
+
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return ioError(name, err)
+	}
+	for _, root := range roots {
+		root, err := resolvePath(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return newKindError(object.PERMISSION_ERROR,
+		"permission denied: `%s` cannot access %s outside of the allowed directories", name, path)
+}
+
+// resolvePath returns the absolute path of path with symbolic links
+// resolved, so that links cannot escape the allowed roots. Missing
+// trailing components, such as a file about to be created, are kept as
+// they are.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	missing := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return filepath.Join(abs, missing), nil
+		}
+		missing = filepath.Join(filepath.Base(abs), missing)
+		abs = parent
+	}
+}
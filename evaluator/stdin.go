@@ -0,0 +1,37 @@
+package evaluator
+
+import (
+	"Nutlang/object"
+	"io"
+)
+
+func readStdinLine(ctx object.Context, name string) object.Object {
+	return readLine(name, ctx.Streams().Stdin)
+}
+
+func readStdin(ctx object.Context) object.Object {
+	data, err := io.ReadAll(ctx.Streams().Stdin)
+	if err != nil {
+		return ioError("readStdin", err)
+	}
+	return &object.String{Value: string(data)}
+}
+
+// stdinLineIterator lazily yields the remaining lines of standard input.
+func stdinLineIterator(ctx object.Context) *object.Iterator {
+	done := false
+	return &object.Iterator{
+		Next: func() (object.Object, bool) {
+			if done {
+				return nil, false
+			}
+
+			line := readStdinLine(ctx, "stdinLines")
+			if line == NULL {
+				return nil, false
+			}
+			done = isError(line)
+			return line, true
+		},
+	}
+}
@@ -0,0 +1,25 @@
+package evaluator
+
+import (
+	"Nutlang/object"
+	"time"
+)
+
+// sleep pauses the program for d, or until it is cancelled.
+func sleep(ctx object.Context, d time.Duration) object.Object {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	var done <-chan struct{}
+	if cancel := ctx.Runtime().Limits.Context; cancel != nil {
+		done = cancel.Done()
+	}
+
+	select {
+	case <-timer.C:
+		return NULL
+	case <-done:
+		return limitError(ctx.Runtime(), "execution cancelled: %s",
+			ctx.Runtime().Limits.Context.Err())
+	}
+}
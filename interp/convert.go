@@ -0,0 +1,416 @@
+package interp
+
+import (
+	"Nutlang/evaluator"
+	"Nutlang/object"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+var (
+	objectType  = reflect.TypeOf((*object.Object)(nil)).Elem()
+	contextType = reflect.TypeOf((*object.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToObject converts a Go value to a Nut value. It accepts nil, booleans,
+// numbers, strings, slices, arrays, maps, functions and object.Objects.
+//
+// Functions become builtins converting their arguments and results; a
+// non-nil error as last result becomes a Nut error, and a first
+// parameter of type object.Context receives the calling context.
+func ToObject(value any) (object.Object, error) {
+	if value == nil {
+		return evaluator.NULL, nil
+	}
+	if obj, ok := value.(object.Object); ok {
+		return obj, nil
+	}
+	return toObject(reflect.ValueOf(value))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("cannot convert %d to a Nut value: overflows INTEGER", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			elem, err := ToObject(v.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elem
+		}
+		return &object.Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return mapToHash(v)
+
+	case reflect.Func:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return funcToBuiltin(v)
+
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return ToObject(v.Elem().Interface())
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a Nut value", v.Type())
+}
+
+// mapToHash converts a Go map to a hash whose keys are inserted in
+// sorted order, since Go map iteration order is random.
+func mapToHash(v reflect.Value) (object.Object, error) {
+	type entry struct {
+		key, value object.Object
+	}
+
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := ToObject(iter.Key().Interface())
+		if err != nil {
+			return nil, err
+		}
+		value, err := ToObject(iter.Value().Interface())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key, value})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key.Inspect() < entries[j].key.Inspect()
+	})
+
+	hash := object.NewHash()
+	for _, e := range entries {
+		if !hash.Set(e.key, e.value) {
+			return nil, fmt.Errorf("unusable as hash key: %s", e.key.Type())
+		}
+	}
+	return hash, nil
+}
+
+func funcToBuiltin(fn reflect.Value) (object.Object, error) {
+	t := fn.Type()
+
+	// The context is passed implicitly rather than by Nut code
+	offset := 0
+	if t.NumIn() > 0 && t.In(0) == contextType {
+		offset = 1
+	}
+
+	numOut := t.NumOut()
+	returnsError := numOut > 0 && t.Out(numOut-1) == errorType
+	if returnsError {
+		numOut--
+	}
+	if numOut > 1 {
+		return nil, fmt.Errorf("cannot convert %s to a Nut value: too many results", t)
+	}
+
+	return &object.Builtin{
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			params := t.NumIn() - offset
+			if t.IsVariadic() {
+				if len(args) < params-1 {
+					return newError("wrong number of arguments. got=%d, want at least %d",
+						len(args), params-1)
+				}
+			} else if len(args) != params {
+				return newError("wrong number of arguments. got=%d, want=%d", len(args), params)
+			}
+
+			in := make([]reflect.Value, 0, len(args)+offset)
+			if offset == 1 {
+				in = append(in, reflect.ValueOf(&ctx).Elem())
+			}
+			for i, arg := range args {
+				var paramType reflect.Type
+				if t.IsVariadic() && i+offset >= t.NumIn()-1 {
+					paramType = t.In(t.NumIn() - 1).Elem()
+				} else {
+					paramType = t.In(i + offset)
+				}
+
+				value, err := fromObject(ctx, arg, paramType)
+				if err != nil {
+					return newError("argument %d: %s", i+1, err)
+				}
+				in = append(in, value)
+			}
+
+			out := fn.Call(in)
+			if returnsError {
+				if err, _ := out[len(out)-1].Interface().(error); err != nil {
+					// Errors of Nut callbacks keep their kind
+					var runtimeErr *RuntimeError
+					if errors.As(err, &runtimeErr) {
+						return runtimeErr.Object
+					}
+					return newError("%s", err)
+				}
+			}
+			if numOut == 0 {
+				return evaluator.NULL
+			}
+
+			result, err := ToObject(out[0].Interface())
+			if err != nil {
+				return newError("%s", err)
+			}
+			return result
+		},
+	}, nil
+}
+
+// ToGo converts a Nut value to a Go value: INTEGER to int, FLOAT to
+// float64, STRING to string, CHAR to rune, BOOLEAN to bool, null to nil,
+// ARRAY to []any and HASH to map[string]any, or map[any]any when some
+// keys are not strings. Other values are returned unchanged.
+func ToGo(obj object.Object) any {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Integer:
+		return int(obj.Value)
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Char:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Array:
+		values := make([]any, len(obj.Elements))
+		for i, elem := range obj.Elements {
+			values[i] = ToGo(elem)
+		}
+		return values
+	case *object.Hash:
+		return hashToMap(obj)
+	}
+	return obj
+}
+
+func hashToMap(hash *object.Hash) any {
+	pairs := hash.Pairs()
+
+	strs := make(map[string]any, len(pairs))
+	for _, pair := range pairs {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			break
+		}
+		strs[key.Value] = ToGo(pair.Value)
+	}
+	if len(strs) == len(pairs) {
+		return strs
+	}
+
+	values := make(map[any]any, len(pairs))
+	for _, pair := range pairs {
+		key := ToGo(pair.Key)
+		// Arrays and hashes are not comparable in Go
+		if _, ok := pair.Key.(*object.Array); ok {
+			key = pair.Key.Inspect()
+		}
+		values[key] = ToGo(pair.Value)
+	}
+	return values
+}
+
+// fromObject converts obj to a Go value of type t.
+func fromObject(ctx object.Context, obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if t == objectType {
+		return reflect.ValueOf(&obj).Elem(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		value := ToGo(obj)
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		if !reflect.TypeOf(value).AssignableTo(t) {
+			break
+		}
+		return reflect.ValueOf(value).Convert(t), nil
+
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(t), nil
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n int64
+		switch obj := obj.(type) {
+		case *object.Integer:
+			n = obj.Value
+		case *object.Char:
+			n = int64(obj.Value)
+		default:
+			return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+		}
+
+		value := reflect.New(t).Elem()
+		if value.CanInt() {
+			if value.OverflowInt(n) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", n, t)
+			}
+			value.SetInt(n)
+		} else {
+			if n < 0 || value.OverflowUint(uint64(n)) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", n, t)
+			}
+			value.SetUint(uint64(n))
+		}
+		return value, nil
+
+	case reflect.Float32, reflect.Float64:
+		switch obj := obj.(type) {
+		case *object.Float:
+			return reflect.ValueOf(obj.Value).Convert(t), nil
+		case *object.Integer:
+			return reflect.ValueOf(float64(obj.Value)).Convert(t), nil
+		}
+
+	case reflect.String:
+		if s, ok := obj.(*object.String); ok {
+			return reflect.ValueOf(s.Value).Convert(t), nil
+		}
+
+	case reflect.Slice:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			break
+		}
+		slice := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		for i, elem := range arr.Elements {
+			value, err := fromObject(ctx, elem, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			slice.Index(i).Set(value)
+		}
+		return slice, nil
+
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			break
+		}
+		m := reflect.MakeMapWithSize(t, hash.Len())
+		for _, pair := range hash.Pairs() {
+			key, err := fromObject(ctx, pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			value, err := fromObject(ctx, pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			m.SetMapIndex(key, value)
+		}
+		return m, nil
+
+	case reflect.Func:
+		switch obj.(type) {
+		case *object.Function, *object.Builtin:
+			return callbackFunc(ctx, obj, t), nil
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+}
+
+// callbackFunc returns a Go function of type t calling the Nut function fn.
+func callbackFunc(ctx object.Context, fn object.Object, t reflect.Type) reflect.Value {
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		args := make([]object.Object, len(in))
+		for i, value := range in {
+			arg, err := ToObject(value.Interface())
+			if err != nil {
+				return callbackResults(ctx, t, newError("%s", err))
+			}
+			args[i] = arg
+		}
+		return callbackResults(ctx, t, ctx.Call(fn, args...))
+	})
+}
+
+func callbackResults(ctx object.Context, t reflect.Type, result object.Object) []reflect.Value {
+	out := make([]reflect.Value, t.NumOut())
+	for i := range out {
+		out[i] = reflect.Zero(t.Out(i))
+	}
+
+	last := t.NumOut() - 1
+	returnsError := last >= 0 && t.Out(last) == errorType
+	if errObj, ok := result.(*object.Error); ok {
+		if returnsError {
+			out[last] = errorValue(&RuntimeError{Object: errObj})
+		}
+		return out
+	}
+
+	if t.NumOut() > 0 && (!returnsError || last > 0) {
+		value, err := fromObject(ctx, result, t.Out(0))
+		if err != nil {
+			if returnsError {
+				out[last] = errorValue(err)
+			}
+			return out
+		}
+		out[0] = value
+	}
+	return out
+}
+
+// newError returns a runtime error, like the errors of the evaluator's
+// builtins.
+func newError(format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: object.RUNTIME_ERROR}
+}
+
+func errorValue(err error) reflect.Value {
+	value := reflect.New(errorType).Elem()
+	value.Set(reflect.ValueOf(err))
+	return value
+}
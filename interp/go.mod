@@ -0,0 +1,3 @@
+module Nutlang/interp
+
+go 1.21.5
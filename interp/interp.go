@@ -0,0 +1,237 @@
+// Package interp embeds the Nut interpreter in Go programs.
+//
+//	in, err := interp.New(interp.Options{})
+//	...
+//	in.Set("limit", 10)
+//	in.Run(`let double = fn(x) { x * 2 }`)
+//	result, err := in.Call("double", 21)
+package interp
+
+import (
+	"Nutlang/ast"
+	"Nutlang/evaluator"
+	"Nutlang/lexer"
+	"Nutlang/object"
+	"Nutlang/parser"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Options configure a new Interpreter. Nil streams default to the
+// process's standard streams.
+type Options struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Builtins are registered as with Register; see Set for the
+	// accepted values.
+	Builtins map[string]any
+
+	// MaxSteps, MaxCallDepth and MaxAllocations limit every Run and
+	// Call, as described by object.Limits. Exceeding one, like the
+	// cancellation of RunContext, fails with a RuntimeError that scripts
+	// cannot catch.
+	MaxSteps       int64
+	MaxCallDepth   int64
+	MaxAllocations int64
+
+	// Allow, when not nil, lists the only capabilities builtins may use,
+	// and Deny the ones they may not. Only DefaultCapabilities are
+	// allowed by default.
+	Allow []object.Capability
+	Deny  []object.Capability
+	// FSRoots, when not empty, restrict filesystem access to the files
+	// inside these directories
+	FSRoots []string
+	// Args are returned by the `args` builtin
+	Args []string
+}
+
+// DefaultCapabilities are the capabilities of interpreters whose Options
+// do not Allow any. Writing files must be allowed explicitly, for
+// instance with
+//
+//	Allow: append(interp.DefaultCapabilities, object.FS_WRITE_CAP)
+var DefaultCapabilities = []object.Capability{
+	object.FS_READ_CAP,
+	object.TIME_CAP,
+	object.RANDOM_CAP,
+}
+
+// Interpreter runs Nut programs in an environment of globals that
+// persists between calls to Run.
+type Interpreter struct {
+	env *object.Environment
+}
+
+// ParseError reports the errors found while parsing a program.
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return "parse errors:\n\t" + strings.Join(e.Errors, "\n\t")
+}
+
+// RuntimeError reports an error raised while evaluating a program.
+type RuntimeError struct {
+	Object *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Object.Message
+}
+
+func New(opts Options) (*Interpreter, error) {
+	if opts.Stdin == nil {
+		opts.Stdin = os.Stdin
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+
+	runtime := &object.Runtime{
+		Streams: object.NewStreams(opts.Stdin, opts.Stdout, opts.Stderr),
+		Limits: object.Limits{
+			MaxSteps:       opts.MaxSteps,
+			MaxCallDepth:   opts.MaxCallDepth,
+			MaxAllocations: opts.MaxAllocations,
+		},
+		Permissions: object.Permissions{Roots: opts.FSRoots},
+		Args:        opts.Args,
+	}
+	if opts.Allow == nil {
+		opts.Allow = DefaultCapabilities
+	}
+	runtime.Permissions.Only(opts.Allow...)
+	runtime.Permissions.Deny(opts.Deny...)
+	in := &Interpreter{env: object.NewEnvironmentWithRuntime(runtime)}
+
+	for name, fn := range opts.Builtins {
+		if err := in.Register(name, fn); err != nil {
+			return nil, err
+		}
+	}
+	return in, nil
+}
+
+// Run evaluates src and returns the value of its last statement
+// converted with ToGo.
+func (in *Interpreter) Run(src string) (any, error) {
+	return in.RunContext(context.Background(), src)
+}
+
+// RunContext is like Run but stops the program with an error when ctx
+// is done.
+func (in *Interpreter) RunContext(ctx context.Context, src string) (any, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	in.start(ctx)
+	return in.result(evaluator.Eval(program, in.env))
+}
+
+// Call calls the global Nut function or builtin named name with args
+// converted with ToObject.
+func (in *Interpreter) Call(name string, args ...any) (any, error) {
+	return in.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is like Call but stops the function with an error when
+// ctx is done.
+func (in *Interpreter) CallContext(ctx context.Context, name string, args ...any) (any, error) {
+	in.start(ctx)
+
+	// Resolve name like Nut code would, so that builtins can be called
+	fn := evaluator.Eval(&ast.Identifier{Value: name}, in.env)
+	if errObj, ok := fn.(*object.Error); ok {
+		return nil, &RuntimeError{Object: errObj}
+	}
+
+	objects := make([]object.Object, len(args))
+	for i, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d to %s: %w", i+1, name, err)
+		}
+		objects[i] = obj
+	}
+
+	return in.result(evaluator.Call(fn, objects, in.env))
+}
+
+// Set defines the global name with value converted with ToObject.
+func (in *Interpreter) Set(name string, value any) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return fmt.Errorf("cannot set %s: %w", name, err)
+	}
+
+	in.env.Set(name, obj)
+	return nil
+}
+
+// Get returns the global name converted with ToGo.
+func (in *Interpreter) Get(name string) (any, bool) {
+	obj, ok := in.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return ToGo(obj), true
+}
+
+// Register makes fn callable from Nut code as name in this interpreter
+// only. fn is either an object.BuiltinFunction or any Go function, whose
+// arguments and results are converted automatically.
+func (in *Interpreter) Register(name string, fn any) error {
+	var builtin *object.Builtin
+
+	switch fn := fn.(type) {
+	case object.BuiltinFunction:
+		builtin = &object.Builtin{Fn: fn}
+	case func(object.Context, ...object.Object) object.Object:
+		builtin = &object.Builtin{Fn: fn}
+	default:
+		obj, err := ToObject(fn)
+		if err != nil {
+			return fmt.Errorf("cannot register %s: %w", name, err)
+		}
+		b, ok := obj.(*object.Builtin)
+		if !ok {
+			return fmt.Errorf("cannot register %s: %T is not a function", name, fn)
+		}
+		builtin = b
+	}
+
+	in.env.Set(name, builtin)
+	return nil
+}
+
+// Close closes the files left open by the programs run by in.
+func (in *Interpreter) Close() {
+	in.env.Runtime().CloseFiles()
+}
+
+// start resets the limits before running code on behalf of the host.
+func (in *Interpreter) start(ctx context.Context) {
+	runtime := in.env.Runtime()
+	runtime.Reset()
+	runtime.Limits.Context = ctx
+}
+
+func (in *Interpreter) result(obj object.Object) (any, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Object: errObj}
+	}
+	return ToGo(obj), nil
+}
@@ -0,0 +1,320 @@
+package interp
+
+import (
+	"Nutlang/object"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newInterpreter(t *testing.T, opts Options) *Interpreter {
+	t.Helper()
+	in, err := New(opts)
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	return in
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`1 + 2`, 3},
+		{`1.5 * 2`, 3.0},
+		{`"a" + "b"`, "a" + "b"},
+		{`'a'`, 'a'},
+		{`1 < 2`, true},
+		{`first([])`, nil},
+		{`[1, "a", [true]]`, []any{1, "a", []any{true}}},
+		{`{"a": 1, "b": [2]}`, map[string]any{"a": 1, "b": []any{2}}},
+		{`{1: "a", "b": 2}`, map[any]any{1: "a", "b": 2}},
+	}
+
+	for _, tt := range tests {
+		in := newInterpreter(t, Options{})
+		result, err := in.Run(tt.input)
+		if err != nil {
+			t.Errorf("Run(%q) failed: %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Run(%q) = %#v, want %#v", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	in := newInterpreter(t, Options{})
+
+	_, err := in.Run(`let = 1`)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || len(parseErr.Errors) == 0 {
+		t.Errorf("expected a ParseError, got %#v", err)
+	}
+
+	_, err = in.Run(`1 + true`)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a RuntimeError, got %#v", err)
+	}
+	if err.Error() != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message: %q", err.Error())
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	in := newInterpreter(t, Options{})
+
+	if err := in.Set("limit", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Set("names", []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Set("ages", map[string]int{"b": 2, "a": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := in.Run(`let total = limit + len(names); str(keys(ages))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "[a, b]" {
+		t.Errorf("map keys not sorted: %v", result)
+	}
+
+	total, ok := in.Get("total")
+	if !ok || total != 12 {
+		t.Errorf("Get(total) = %v, %v", total, ok)
+	}
+	if _, ok := in.Get("missing"); ok {
+		t.Errorf("Get(missing) should fail")
+	}
+
+	if err := in.Set("ch", make(chan int)); err == nil {
+		t.Errorf("expected an error setting a channel")
+	}
+	if err := in.Set("big", uint64(math.MaxUint64)); err == nil {
+		t.Errorf("expected an error setting an integer out of range")
+	}
+}
+
+func TestCall(t *testing.T) {
+	in := newInterpreter(t, Options{})
+	if _, err := in.Run(`let add = fn(a, b) { a + b }; let fail = fn() { 1 + true }`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := in.Call("add", 1, 2)
+	if err != nil || result != 3 {
+		t.Errorf("Call(add) = %v, %v", result, err)
+	}
+	result, err = in.Call("len", []int{1, 2, 3})
+	if err != nil || result != 3 {
+		t.Errorf("Call(len) = %v, %v", result, err)
+	}
+	if _, err := in.Call("fail"); err == nil {
+		t.Errorf("expected Call(fail) to fail")
+	}
+	if _, err := in.Call("missing"); err == nil {
+		t.Errorf("expected Call(missing) to fail")
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	var out strings.Builder
+	in := newInterpreter(t, Options{
+		Stdout: &out,
+		Builtins: map[string]any{
+			"double": func(x int) int { return x * 2 },
+			"join": func(sep string, parts ...string) string {
+				return strings.Join(parts, sep)
+			},
+			"check": func(x float64) (float64, error) {
+				if x < 0 {
+					return 0, fmt.Errorf("negative: %v", x)
+				}
+				return x, nil
+			},
+			"apply": func(f func(int) int, x int) int { return f(x) },
+			"small": func(x int8) int8 { return x },
+			"size":  func(x uint) uint { return x },
+			"fail":  func(f func() error) error { return f() },
+			"raw": func(ctx object.Context, args ...object.Object) object.Object {
+				return &object.Integer{Value: int64(len(args))}
+			},
+		},
+	})
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`double(21)`, 42},
+		{`join("-", "a", "b", "c")`, "a-b-c"},
+		{`check(2)`, 2.0},
+		{`apply(fn(x) { x + 1 }, 1)`, 2},
+		{`apply(double, 4)`, 8},
+		{`raw(1, 2, 3)`, 3},
+		{`puts("hi")`, nil},
+		{`small(-128)`, -128},
+		{`size(7)`, 7},
+		{`try { check(-1) } catch (e) { e["kind"] }`, "RuntimeError"},
+		{`try { fail(fn() { missing }) } catch (e) { e["kind"] }`, "NameError"},
+	}
+
+	for _, tt := range tests {
+		result, err := in.Run(tt.input)
+		if err != nil {
+			t.Errorf("Run(%q) failed: %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Run(%q) = %#v, want %#v", tt.input, result, tt.expected)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`check(-1)`, "negative: -1"},
+		{`double("a")`, "argument 1: cannot use STRING as int"},
+		{`double()`, "wrong number of arguments. got=0, want=1"},
+		{`small(128)`, "argument 1: 128 overflows int8"},
+		{`size(-1)`, "argument 1: -1 overflows uint"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := in.Run(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Run(%q) error = %v, want %q", tt.input, err, tt.expected)
+		}
+	}
+
+	if out.String() != "hi\n" {
+		t.Errorf("stdout not redirected: %q", out.String())
+	}
+
+	other := newInterpreter(t, Options{})
+	if _, err := other.Run(`double(1)`); err == nil {
+		t.Errorf("builtins leaked to another interpreter")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	in := newInterpreter(t, Options{MaxSteps: 10000, MaxCallDepth: 20})
+
+	_, err := in.Run(`for (true) { 1 }`)
+	if err == nil || err.Error() != "step limit of 10000 exceeded" {
+		t.Errorf("expected step limit error, got %v", err)
+	}
+
+	// Every run gets a fresh budget
+	if _, err := in.Run(`let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(10)`); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if _, err := in.Call("f", 30); err == nil || err.Error() != "maximum call depth of 20 exceeded" {
+		t.Errorf("expected call depth error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	unlimited := newInterpreter(t, Options{})
+	if _, err := unlimited.RunContext(ctx, `for (true) { 1 }`); err == nil ||
+		err.Error() != "execution cancelled: context deadline exceeded" {
+		t.Errorf("expected cancellation error, got %v", err)
+	}
+
+	// Catching the cancellation does not keep the program running
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := unlimited.RunContext(ctx,
+		`let n = 0; for (true) { try { for (true) { n = n + 1 } } catch (e) { n = 0 } }`); err == nil ||
+		err.Error() != "execution cancelled: context deadline exceeded" {
+		t.Errorf("expected cancellation error, got %v", err)
+	}
+	if result, err := unlimited.Run(`1 + 1`); err != nil || result != 2 {
+		t.Errorf("Run after cancellation = %v, %v", result, err)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	in := newInterpreter(t, Options{
+		Allow:   []object.Capability{object.FS_READ_CAP, object.TIME_CAP},
+		Deny:    []object.Capability{object.TIME_CAP},
+		FSRoots: []string{t.TempDir()},
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`rand()`, "permission denied: `rand` needs the random capability"},
+		{`now()`, "permission denied: `now` needs the time capability"},
+		{`exists("/")`, "permission denied: `exists` cannot access / outside of the allowed directories"},
+	}
+
+	for _, tt := range tests {
+		_, err := in.Run(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Run(%q) error = %v, want %q", tt.input, err, tt.expected)
+		}
+	}
+
+	if result, err := in.Run(`len(readFile("` + "/dev/null" + `"))`); err == nil {
+		t.Errorf("expected readFile outside of the roots to fail, got %v", result)
+	}
+
+	// Writing files must be allowed explicitly
+	path := filepath.Join(t.TempDir(), "out.txt")
+	sandboxed := newInterpreter(t, Options{})
+	sandboxed.Set("path", path)
+	if _, err := sandboxed.Run(`writeFile(path, "x")`); err == nil ||
+		err.Error() != "permission denied: `writeFile` needs the fs-write capability" {
+		t.Errorf("expected writeFile to be denied by default, got %v", err)
+	}
+	if result, err := sandboxed.Run(`exists(path)`); err != nil || result != false {
+		t.Errorf("expected exists to be allowed by default, got %v, %v", result, err)
+	}
+
+	writer := newInterpreter(t, Options{Allow: append(DefaultCapabilities, object.FS_WRITE_CAP)})
+	writer.Set("path", path)
+	if _, err := writer.Run(`writeFile(path, "x")`); err != nil {
+		t.Errorf("writeFile failed: %s", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	os.WriteFile(path, []byte("a\nb\n"), 0o644)
+
+	first := newInterpreter(t, Options{})
+	second := newInterpreter(t, Options{})
+	for _, in := range []*Interpreter{first, second} {
+		in.Set("path", path)
+		if _, err := in.Run(`let f = open(path)`); err != nil {
+			t.Fatalf("open failed: %s", err)
+		}
+	}
+
+	// Closing an interpreter leaves the files of the others open
+	first.Close()
+	if result, err := second.Run(`readLine(f)`); err != nil || result != "a" {
+		t.Errorf("readLine after closing another interpreter = %v, %v", result, err)
+	}
+	if _, err := first.Run(`readLine(f)`); err == nil ||
+		err.Error() != "file "+path+" already closed" {
+		t.Errorf("expected closed file error, got %v", err)
+	}
+	second.Close()
+}
@@ -2,7 +2,9 @@ package lexer
 
 import (
 	"Nutlang/token"
+	"fmt"
 	"strings"
+	"unicode/utf8"
 )
 
 type Lexer struct {
@@ -10,6 +12,18 @@ type Lexer struct {
 	position     int
 	readPosition int
 	ch           byte
+
+	// interpolations holds, for every interpolated string we are inside
+	// of, the number of unclosed '{' within its current ${...}.
+	interpolations []int
+
+	errors []string
+
+	// line and lineStart track the line of offset scanned, to locate
+	// tokens without rescanning the input
+	scanned   int
+	line      int
+	lineStart int
 }
 
 func New(input string) *Lexer {
@@ -46,19 +60,50 @@ func (l *Lexer) makeTwoCharToken(peekLiteral byte, oneCharTokenType token.TokenT
 }
 
 func (l *Lexer) NextToken() token.Token {
+	l.skipWhitespace()
+	start := l.position
+
+	tok := l.nextToken()
+	tok.Line, tok.Column = l.lineColumn(start)
+	return tok
+}
+
+// lineColumn returns the line and column of offset, which must not be
+// before the offset of a previous call.
+func (l *Lexer) lineColumn(offset int) (int, int) {
+	for ; l.scanned < offset && l.scanned < len(l.input); l.scanned++ {
+		if l.input[l.scanned] == '\n' {
+			l.line++
+			l.lineStart = l.scanned + 1
+		}
+	}
+	return l.line + 1, offset - l.lineStart + 1
+}
+
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
 
 	switch l.ch {
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '-' {
+			tok = l.makeTwoCharToken('-', token.MINUS, token.DECREMENT)
+		} else {
+			tok = l.makeTwoCharToken('=', token.MINUS, token.MINUS_ASSIGN)
+		}
 	case '!':
 		tok = l.makeTwoCharToken('=', token.BANG, token.NOT_EQ)
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.makeTwoCharToken('=', token.SLASH, token.SLASH_ASSIGN)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			l.readChar()
+			tok = l.makeTwoCharToken('=', token.POWER, token.POWER_ASSIGN)
+			tok.Literal = "*" + tok.Literal
+		} else {
+			tok = l.makeTwoCharToken('=', token.ASTERISK, token.ASTERISK_ASSIGN)
+		}
 	case '<':
 		if l.peekChar() == '=' {
 			tok = l.makeTwoCharToken('=', token.LT, token.LTE)
@@ -72,13 +117,25 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.GT, l.ch)
 		}
 	case '%':
-		tok = newToken(token.MODULO, l.ch)
+		tok = l.makeTwoCharToken('=', token.MODULO, token.MODULO_ASSIGN)
 	case '&':
-		tok = l.makeTwoCharToken('&', token.BITWISEAND, token.AND)
+		if l.peekChar() == '=' {
+			tok = l.makeTwoCharToken('=', token.BITWISEAND, token.BITWISEAND_ASSIGN)
+		} else {
+			tok = l.makeTwoCharToken('&', token.BITWISEAND, token.AND)
+		}
 	case '|':
-		tok = l.makeTwoCharToken('|', token.BITWISEOR, token.OR)
+		if l.peekChar() == '=' {
+			tok = l.makeTwoCharToken('=', token.BITWISEOR, token.BITWISEOR_ASSIGN)
+		} else {
+			tok = l.makeTwoCharToken('|', token.BITWISEOR, token.OR)
+		}
 	case '=':
-		tok = l.makeTwoCharToken('=', token.ASSIGN, token.EQ)
+		if l.peekChar() == '>' {
+			tok = l.makeTwoCharToken('>', token.ASSIGN, token.ARROW)
+		} else {
+			tok = l.makeTwoCharToken('=', token.ASSIGN, token.EQ)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case '(':
@@ -88,16 +145,57 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.peekChar() == '+' {
+			tok = l.makeTwoCharToken('+', token.PLUS, token.INCREMENT)
+		} else {
+			tok = l.makeTwoCharToken('=', token.PLUS, token.PLUS_ASSIGN)
+		}
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		n := len(l.interpolations)
+		if n > 0 && l.interpolations[n-1] == 0 {
+			// This closes a ${...}, the string continues after it
+			l.interpolations = l.interpolations[:n-1]
+			tok = l.readStringToken(token.INTERP_MID, token.INTERP_END)
+		} else {
+			if n > 0 {
+				l.interpolations[n-1]--
+			}
+			tok = newToken(token.RBRACE, l.ch)
+		}
 	case '"':
+		if l.peekChar() == '"' && l.peekCharAt(2) == '"' {
+			tok.Type = token.STRING
+			tok.Literal = l.readTripleQuotedString()
+		} else {
+			tok = l.readStringToken(token.INTERP_START, token.STRING)
+		}
+	case '`':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readRawString()
+	case '\'':
+		tok.Type = token.CHAR
+		tok.Literal = l.readCharLiteral()
 	case ':':
-		tok = newToken(token.COLON, l.ch)
+		tok = l.makeTwoCharToken('=', token.COLON, token.BIND)
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else if l.peekChar() == '.' {
+			l.readChar()
+			tok = l.makeTwoCharToken('=', token.RANGE, token.RANGE_INC)
+			tok.Literal = "." + tok.Literal
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
+	case '?':
+		tok = newToken(token.QUESTION, l.ch)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
@@ -106,14 +204,17 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
-		if isLetter(l.ch) {
+		if l.ch == 'r' && l.peekChar() == '"' {
+			tok.Type = token.REGEX
+			tok.Literal = l.readRegex()
+		} else if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Literal = l.readNumber()
-			// Check we stopped on "." -> FLOAT
-			if l.ch == '.' {
+			// Check we stopped on "." -> FLOAT, but not on the ".." of 1..5
+			if l.ch == '.' && l.peekChar() != '.' {
 				// Advance
 				l.readChar()
 				if isDigit(l.ch) {
@@ -135,51 +236,244 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-func (l *Lexer) readString() string {
-	/*
-		position := l.position + 1
-		for {
+// readStringToken reads the rest of a string literal. If it stops at a
+// "${" the token gets the interpolated type and the lexer starts tracking
+// the embedded expression, otherwise it gets the end type.
+func (l *Lexer) readStringToken(interpolated, end token.TokenType) token.Token {
+	literal, interpolation := l.readString()
+	if interpolation {
+		l.interpolations = append(l.interpolations, 0)
+		return token.Token{Type: interpolated, Literal: literal}
+	}
+	return token.Token{Type: end, Literal: literal}
+}
+
+// readString reads up to the closing '"' or the next "${", reporting
+// whether it stopped at the latter.
+func (l *Lexer) readString() (string, bool) {
+	start := l.position
+	b := strings.Builder{}
+
+	for {
+		l.readChar()
+
+		switch {
+		case l.ch == 0:
+			l.errorf(start, "unterminated string")
+			return b.String(), false
+		case l.ch == '"':
+			return b.String(), false
+		case l.ch == '$' && l.peekChar() == '{':
+			l.readChar()
+			return b.String(), true
+		case l.ch == '\\':
 			l.readChar()
-			if l.ch == '"' || l.ch == 0 {
-				break
+			if ch, ok := escapes[l.ch]; ok {
+				b.WriteByte(ch)
+			} else if l.ch != 0 {
+				l.errorf(l.position-1, "unknown escape sequence \\%c", l.ch)
 			}
+		default:
+			b.WriteByte(l.ch)
 		}
-		return l.input[position:l.position]
-	*/
+	}
+}
+
+// escapes maps the character following a '\\' in a string to the
+// character it stands for.
+var escapes = map[byte]byte{
+	'"':  '"',
+	'\'': '\'',
+	'$':  '$',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'\\': '\\',
+}
+
+// readCharLiteral reads a '-delimited character literal, which must hold
+// exactly one character or escape sequence.
+func (l *Lexer) readCharLiteral() string {
+	start := l.position
 	b := strings.Builder{}
+
 	for {
 		l.readChar()
 
-		// Support some basic escapes like \"
+		if l.ch == 0 || l.ch == '\n' {
+			l.errorf(start, "unterminated character literal")
+			return b.String()
+		}
+		if l.ch == '\'' {
+			break
+		}
 		if l.ch == '\\' {
-			switch l.peekChar() {
-			case '"':
-				b.WriteByte('"')
-			case 'n':
-				b.WriteByte('\n')
-			case 'r':
-				b.WriteByte('\r')
-			case 't':
-				b.WriteByte('\t')
-			case '\\':
-				b.WriteByte('\\')
+			l.readChar()
+			if ch, ok := escapes[l.ch]; ok {
+				b.WriteByte(ch)
+			} else {
+				l.errorf(l.position-1, "unknown escape sequence \\%c", l.ch)
 			}
+			continue
+		}
+		b.WriteByte(l.ch)
+	}
+
+	if utf8.RuneCountInString(b.String()) != 1 {
+		l.errorf(start, "character literal must hold exactly one character, got %q", b.String())
+	}
+	return b.String()
+}
 
-			// Skip over the '\\' and the matched single escape char
+// readRawString reads a `-delimited string. Everything up to the closing
+// backtick, line breaks and backslashes included, is taken literally.
+func (l *Lexer) readRawString() string {
+	start := l.position
+	position := l.position + 1
+
+	for {
+		l.readChar()
+		if l.ch == '`' {
+			break
+		}
+		if l.ch == 0 {
+			l.errorf(start, "unterminated raw string")
+			break
+		}
+	}
+
+	return l.input[position:l.position]
+}
+
+// readRegex reads a r"..." regular expression literal. Backslashes are
+// kept as they are, so \d needs no escaping and \" does not end it.
+func (l *Lexer) readRegex() string {
+	start := l.position
+	l.readChar()
+	position := l.position + 1
+
+	for {
+		l.readChar()
+		if l.ch == '\\' && l.peekChar() == '"' {
+			l.readChar()
+			continue
+		}
+		if l.ch == '"' {
+			break
+		}
+		if l.ch == 0 {
+			l.errorf(start, "unterminated regular expression")
+			break
+		}
+	}
+
+	return l.input[position:l.position]
+}
+
+// readTripleQuotedString reads a """-delimited string, which may span
+// several lines. A line break right after the opening quotes, the line
+// holding the closing quotes when it is otherwise blank, and the
+// indentation shared by all lines are dropped before escapes are applied.
+func (l *Lexer) readTripleQuotedString() string {
+	start := l.position
+	raw := strings.Builder{}
+
+	// Move onto the third opening quote
+	l.readChar()
+	l.readChar()
+
+	for {
+		l.readChar()
+
+		if l.ch == 0 {
+			l.errorf(start, "unterminated string")
+			break
+		}
+		if l.ch == '"' && l.peekChar() == '"' && l.peekCharAt(2) == '"' {
+			l.readChar()
+			l.readChar()
+			break
+		}
+
+		raw.WriteByte(l.ch)
+		if l.ch == '\\' && l.peekChar() != 0 {
+			// Keep escaped quotes from ending the string
 			l.readChar()
+			raw.WriteByte(l.ch)
+		}
+	}
+
+	return l.unescape(dedent(raw.String()), start)
+}
+
+// dedent strips the indentation common to all non-blank lines of s, along
+// with a blank first and last line.
+func dedent(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if len(lines) > 1 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) > 1 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
 			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
 		} else {
-			if l.ch == '"' || l.ch == 0 {
-				break
-			}
+			lines[i] = line[indent:]
 		}
+	}
 
-		b.WriteByte(l.ch)
+	return strings.Join(lines, "\n")
+}
+
+// unescape applies the escape sequences in s, reporting unknown ones at
+// the string starting at offset.
+func (l *Lexer) unescape(s string, offset int) string {
+	b := strings.Builder{}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		if ch, ok := escapes[s[i]]; ok {
+			b.WriteByte(ch)
+		} else {
+			l.errorf(offset, "unknown escape sequence \\%c", s[i])
+		}
 	}
 
 	return b.String()
 }
 
+// Errors returns the problems found in the input so far, such as
+// unterminated strings.
+func (l *Lexer) Errors() []string {
+	return l.errors
+}
+
+func (l *Lexer) errorf(offset int, format string, a ...interface{}) {
+	line := 1 + strings.Count(l.input[:offset], "\n")
+	column := offset - strings.LastIndex(l.input[:offset], "\n")
+	msg := fmt.Sprintf("line %d, column %d: ", line, column) + fmt.Sprintf(format, a...)
+	l.errors = append(l.errors, msg)
+}
+
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 	for isLetter(l.ch) {
@@ -215,3 +509,11 @@ func (l *Lexer) peekChar() byte {
 		return l.input[l.readPosition]
 	}
 }
+
+// peekCharAt returns the character offset places after the current one.
+func (l *Lexer) peekCharAt(offset int) byte {
+	if l.position+offset >= len(l.input) {
+		return 0
+	}
+	return l.input[l.position+offset]
+}
@@ -134,13 +134,15 @@ for (a < 10) {
 		{token.LET, "let"},
 		{token.IDENT, "leq"},
 		{token.ASSIGN, "="},
-		{token.FLOAT, "2 <= 5"},
+		{token.INT, "2"},
+		{token.LTE, "<="},
+		{token.INT, "5"},
 		{token.SEMICOLON, ";"},
 		{token.FOR, "for"},
 		{token.LPAREN, "("},
 		{token.IDENT, "a"},
 		{token.LT, "<"},
-		{token.INT, "5"},
+		{token.INT, "10"},
 		{token.RPAREN, ")"},
 		{token.LBRACE, "{"},
 		{token.IDENT, "a"},
@@ -169,3 +171,285 @@ for (a < 10) {
 		}
 	}
 }
+
+func TestInterpolatedString(t *testing.T) {
+	input := `"pos=${x},${ {"a": [y]}["a"] }!" "${"in${1}"}" "\${x}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INTERP_START, "pos="},
+		{token.IDENT, "x"},
+		{token.INTERP_MID, ","},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "y"},
+		{token.RBRACKET, "]"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "a"},
+		{token.RBRACKET, "]"},
+		{token.INTERP_END, "!"},
+		{token.INTERP_START, ""},
+		{token.INTERP_START, "in"},
+		{token.INT, "1"},
+		{token.INTERP_END, ""},
+		{token.INTERP_END, ""},
+		{token.STRING, "${x}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestRawAndMultilineStrings(t *testing.T) {
+	input := "`C:\\dir\\${x}\n\\d+`" + `
+let text = """
+    first
+      second\t"quoted"
+    third
+    """;
+"""one line""" """"""`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "C:\\dir\\${x}\n\\d+"},
+		{token.LET, "let"},
+		{token.IDENT, "text"},
+		{token.ASSIGN, "="},
+		{token.STRING, "first\n  second\t\"quoted\"\nthird"},
+		{token.SEMICOLON, ";"},
+		{token.STRING, "one line"},
+		{token.STRING, ""},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("lexer has unexpected errors: %v", l.Errors())
+	}
+}
+
+func TestCompoundOperators(t *testing.T) {
+	input := `x += 1; x -= 2; x *= 3; x /= 4; x %= 5;
+x **= 2 ** 3; x &= 6 & 7; x |= 8 | 9; x++; --x;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MODULO_ASSIGN, "%="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.POWER_ASSIGN, "**="},
+		{token.INT, "2"},
+		{token.POWER, "**"},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.BITWISEAND_ASSIGN, "&="},
+		{token.INT, "6"},
+		{token.BITWISEAND, "&"},
+		{token.INT, "7"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.BITWISEOR_ASSIGN, "|="},
+		{token.INT, "8"},
+		{token.BITWISEOR, "|"},
+		{token.INT, "9"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.INCREMENT, "++"},
+		{token.SEMICOLON, ";"},
+		{token.DECREMENT, "--"},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestPatternTokens(t *testing.T) {
+	input := `[a, ...b] := x; 1..5 1..=5 1.5 => a`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LBRACKET, "["},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "b"},
+		{token.RBRACKET, "]"},
+		{token.BIND, ":="},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "1"},
+		{token.RANGE, ".."},
+		{token.INT, "5"},
+		{token.INT, "1"},
+		{token.RANGE_INC, "..="},
+		{token.INT, "5"},
+		{token.FLOAT, "1.5"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "a"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`"abc`, []string{"line 1, column 1: unterminated string"}},
+		{"let a = 1;\n  \"a\\db\"", []string{"line 2, column 5: unknown escape sequence \\d"}},
+		{"\"\"\"\n  a\\q\n  \"\"\"", []string{"line 1, column 1: unknown escape sequence \\q"}},
+		{"\"\"\"abc", []string{"line 1, column 1: unterminated string"}},
+		{"x `abc", []string{"line 1, column 3: unterminated raw string"}},
+		{`"a${x}b`, []string{"line 1, column 6: unterminated string"}},
+		{`"a\"b" "\\n"`, nil},
+		{`'ab'`, []string{"line 1, column 1: character literal must hold exactly one character, got \"ab\""}},
+		{`''`, []string{"line 1, column 1: character literal must hold exactly one character, got \"\""}},
+		{`x = 'a`, []string{"line 1, column 5: unterminated character literal"}},
+		{`'\'' '\n' 'é'`, nil},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong number of errors for %q. want=%v, got=%v",
+				tt.input, tt.expected, errors)
+			continue
+		}
+		for i := range errors {
+			if errors[i] != tt.expected[i] {
+				t.Errorf("wrong error for %q. want=%q, got=%q",
+					tt.input, tt.expected[i], errors[i])
+			}
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 1;\n  try {\n\tthrow \"a\" }"
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.TRY, 2, 3},
+		{token.LBRACE, 2, 7},
+		{token.THROW, 3, 2},
+		{token.STRING, 3, 8},
+		{token.RBRACE, 3, 12},
+		{token.EOF, 3, 13},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"Nutlang/object"
 	"Nutlang/repl"
 	"fmt"
 	"os"
@@ -9,6 +10,26 @@ import (
 )
 
 func main() {
+	// nut script.nut [args...] runs a script instead of starting the REPL
+	if len(os.Args) > 1 {
+		src, err := os.ReadFile(os.Args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		runtime := &object.Runtime{
+			Streams: object.NewStreams(os.Stdin, os.Stdout, os.Stderr),
+			Args:    os.Args[2:],
+		}
+		// Scripts are trusted like any program the user runs, so unlike
+		// the interp package the CLI allows every capability
+		runtime.Permissions.Only(object.Capabilities...)
+		if !repl.Run(string(src), runtime) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -0,0 +1,50 @@
+package object
+
+// Capability names a group of builtins with access to the outside world.
+type Capability string
+
+const (
+	FS_READ_CAP  Capability = "fs-read"
+	FS_WRITE_CAP Capability = "fs-write"
+	TIME_CAP     Capability = "time"
+	RANDOM_CAP   Capability = "random"
+)
+
+var Capabilities = []Capability{FS_READ_CAP, FS_WRITE_CAP, TIME_CAP, RANDOM_CAP}
+
+// Permissions decide which capabilities the builtins of a program may
+// use. The zero value allows everything.
+type Permissions struct {
+	Denied map[Capability]bool
+	// Roots, when not empty, restrict filesystem access to the files
+	// inside these directories
+	Roots []string
+}
+
+// Allows reports whether c may be used.
+func (p *Permissions) Allows(c Capability) bool {
+	return !p.Denied[c]
+}
+
+// Deny forbids the use of caps.
+func (p *Permissions) Deny(caps ...Capability) {
+	if p.Denied == nil {
+		p.Denied = map[Capability]bool{}
+	}
+	for _, c := range caps {
+		p.Denied[c] = true
+	}
+}
+
+// Only forbids the use of every capability but caps.
+func (p *Permissions) Only(caps ...Capability) {
+	allowed := map[Capability]bool{}
+	for _, c := range caps {
+		allowed[c] = true
+	}
+	for _, c := range Capabilities {
+		if !allowed[c] {
+			p.Deny(c)
+		}
+	}
+}
@@ -1,19 +1,133 @@
 package object
 
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+)
+
+// Streams are the standard streams of a running program.
+type Streams struct {
+	// Stdin is shared by every builtin reading standard input, so that
+	// buffered but unread input is never lost between calls.
+	Stdin  *bufio.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+func NewStreams(in io.Reader, out, errOut io.Writer) *Streams {
+	return &Streams{Stdin: bufio.NewReader(in), Stdout: out, Stderr: errOut}
+}
+
+// processStreams are the streams of programs that were not given any.
+var processStreams = NewStreams(os.Stdin, os.Stdout, os.Stderr)
+
+// Limits bound the resources a program may use. Zero values mean no
+// limit, except for MaxCallDepth which then has a default. Exceeding a
+// limit stops the program with an Error of kind LimitError, which the
+// host receives and try/catch cannot handle.
+type Limits struct {
+	// Context cancels the program when done
+	Context context.Context
+	// MaxSteps bounds the number of evaluated nodes
+	MaxSteps int64
+	// MaxCallDepth bounds the depth of nested function calls
+	MaxCallDepth int64
+	// MaxAllocations bounds the array elements, hash entries and string
+	// bytes the program creates
+	MaxAllocations int64
+}
+
+// Runtime is the state shared by every environment of a running program.
+type Runtime struct {
+	Streams     *Streams
+	Limits      Limits
+	Permissions Permissions
+	// Args are the command line arguments of the program
+	Args []string
+
+	// Steps, CallDepth and Allocations count the resources used so far
+	Steps       int64
+	CallDepth   int64
+	Allocations int64
+	// Exhausted is the message of the limit error that stopped the
+	// program. Every later step fails with it until Reset, so that the
+	// program cannot carry on by catching the error.
+	Exhausted string
+
+	// Frames describe the function calls in progress, outermost first
+	Frames []string
+
+	// Files are the files opened by the program and not closed yet
+	Files map[*FileDescriptor]struct{}
+}
+
+// CloseFiles closes every file left open by the program.
+func (r *Runtime) CloseFiles() {
+	for fd := range r.Files {
+		fd.Value.Close()
+		fd.Closed = true
+	}
+	clear(r.Files)
+}
+
+// Reset clears the resources counted so far.
+func (r *Runtime) Reset() {
+	r.Steps, r.CallDepth, r.Allocations = 0, 0, 0
+	r.Exhausted = ""
+	r.Frames = nil
+}
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
+	env := NewEnvironmentWithRuntime(outer.runtime)
 	env.outer = outer
 	return env
 }
 
+// NewBindingEnvironment returns an environment holding only bindings, such
+// as those of a match arm or the parameter of a catch clause. Other names
+// are declared and assigned in outer, so that the code seeing the
+// bindings behaves as if it ran in outer.
+func NewBindingEnvironment(outer *Environment, bindings map[string]Object) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	for name, val := range bindings {
+		env.store[name] = val
+	}
+	env.bindingsOnly = true
+	return env
+}
+
 func NewEnvironment() *Environment {
+	return NewEnvironmentWithStreams(processStreams)
+}
+
+// NewEnvironmentWithStreams returns an environment whose programs read
+// and write the given streams.
+func NewEnvironmentWithStreams(streams *Streams) *Environment {
+	return NewEnvironmentWithRuntime(&Runtime{Streams: streams})
+}
+
+func NewEnvironmentWithRuntime(runtime *Runtime) *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, runtime: runtime}
 }
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	consts  map[string]bool
+	outer   *Environment
+	runtime *Runtime
+	// bindingsOnly environments pass the names they do not bind to outer
+	bindingsOnly bool
+}
+
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
+}
+
+func (e *Environment) Streams() *Streams {
+	return e.runtime.Streams
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -25,6 +139,51 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	if e.passes(name) {
+		return e.outer.Set(name, val)
+	}
 	e.store[name] = val
 	return val
 }
+
+// passes reports whether name is declared in the outer environment
+// rather than in e.
+func (e *Environment) passes(name string) bool {
+	if !e.bindingsOnly {
+		return false
+	}
+	_, ok := e.store[name]
+	return !ok
+}
+
+// SetConst binds name to val like Set, but as a constant.
+func (e *Environment) SetConst(name string, val Object) Object {
+	if e.passes(name) {
+		return e.outer.SetConst(name, val)
+	}
+	if e.consts == nil {
+		e.consts = map[string]bool{}
+	}
+	e.consts[name] = true
+	return e.Set(name, val)
+}
+
+// IsConst reports whether name is bound to a constant, in e or the
+// environments it encloses.
+func (e *Environment) IsConst(name string) bool {
+	if _, ok := e.store[name]; ok {
+		return e.consts[name]
+	}
+	if e.outer != nil {
+		return e.outer.IsConst(name)
+	}
+	return false
+}
+
+// IsLocalConst reports whether name is bound to a constant in e itself.
+func (e *Environment) IsLocalConst(name string) bool {
+	if e.passes(name) {
+		return e.outer.IsLocalConst(name)
+	}
+	return e.consts[name]
+}
@@ -0,0 +1,116 @@
+package object
+
+// Equals reports whether a and b hold the same value. Numbers compare by
+// value across INTEGER and FLOAT, arrays and hashes compare element by
+// element, and everything else (functions, builtins, file descriptors)
+// only equals itself.
+func Equals(a, b Object) bool {
+	return equals(a, b, nil)
+}
+
+// comparison is a pair of arrays or hashes being compared. Seeing the same
+// pair again means a value contains itself, and the pair is taken as equal
+// so that comparing it terminates.
+type comparison struct {
+	a, b Object
+}
+
+func equals(a, b Object, seen map[comparison]bool) bool {
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == b.Value
+		case *Float:
+			return float64(a.Value) == b.Value
+		}
+		return false
+
+	case *Float:
+		switch b := b.(type) {
+		case *Float:
+			return a.Value == b.Value
+		case *Integer:
+			return a.Value == float64(b.Value)
+		}
+		return false
+
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+
+	case *Char:
+		b, ok := b.(*Char)
+		return ok && a.Value == b.Value
+
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+
+	case *Regex:
+		b, ok := b.(*Regex)
+		return ok && a.Value.String() == b.Value.String()
+
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		if len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if seen = visit(seen, a, b); seen == nil {
+			return true
+		}
+		for i := range a.Elements {
+			if !equals(a.Elements[i], b.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		if a.Len() != b.Len() {
+			return false
+		}
+		if seen = visit(seen, a, b); seen == nil {
+			return true
+		}
+		for _, pair := range a.Pairs() {
+			other, ok := b.Get(pair.Key)
+			if !ok || !equals(pair.Value, other, seen) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return a == b
+}
+
+// visit records the comparison of a and b, returning nil if it was already
+// in progress.
+func visit(seen map[comparison]bool, a, b Object) map[comparison]bool {
+	if seen == nil {
+		seen = map[comparison]bool{}
+	}
+	if seen[comparison{a, b}] {
+		return nil
+	}
+	seen[comparison{a, b}] = true
+	return seen
+}
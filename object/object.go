@@ -2,18 +2,37 @@ package object
 
 import (
 	"Nutlang/ast"
+	"bufio"
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"os"
+	"regexp"
 	"strings"
 )
 
 type (
 	ObjectType      string
-	BuiltinFunction func(args ...Object) Object
+	BuiltinFunction func(ctx Context, args ...Object) Object
 )
 
+// Context is the view of the running interpreter that builtins get, so
+// that they can call back into Nut code and use its standard streams.
+type Context interface {
+	// Call applies fn, a Nut function or builtin, to args.
+	Call(fn Object, args ...Object) Object
+	// Streams returns the standard streams of the running program.
+	Streams() *Streams
+	// Runtime returns the state of the running program.
+	Runtime() *Runtime
+	// CheckAllocation returns a limit error if the program may not
+	// allocate size more array elements, hash entries or string bytes.
+	// Builtins call it before building large values.
+	CheckAllocation(size int64) *Error
+}
+
 const (
 	INTEGER_OBJ      = "INTEGER"
 	FLOAT_OBJ        = "FLOAT"
@@ -27,6 +46,10 @@ const (
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
 	FD_OBJ           = "FD"
+	REGEX_OBJ        = "REGEX"
+	CHAR_OBJ         = "CHAR"
+	ITERATOR_OBJ     = "ITERATOR"
+	EXCEPTION_OBJ    = "EXCEPTION"
 )
 
 type Object interface {
@@ -44,8 +67,22 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash maps keys to values and remembers the order keys were first
+// inserted in. Keys whose HashKey collide share a bucket in the index and
+// are told apart with Equals, so distinct keys never overwrite each other.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	// entries holds the pairs in insertion order. Deleted pairs are left
+	// behind with a nil Key until the slice is compacted.
+	entries []HashPair
+	index   map[HashKey][]int
+	size    int
+
+	// Frozen hashes cannot be modified
+	Frozen bool
+}
+
+func NewHash() *Hash {
+	return &Hash{index: make(map[HashKey][]int)}
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
@@ -53,7 +90,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.Pairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
 			pair.Key.Inspect(), pair.Value.Inspect()))
 	}
@@ -65,11 +102,165 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// Len returns the number of pairs in the hash.
+func (h *Hash) Len() int { return h.size }
+
+// Pairs returns every pair in the hash, in insertion order.
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, 0, h.size)
+	for _, pair := range h.entries {
+		if pair.Key != nil {
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// lookup returns the hash key of key and the position of its pair in
+// entries, or -1 if it is not in the hash.
+func (h *Hash) lookup(key Object) (HashKey, int, bool) {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return hashKey, -1, false
+	}
+
+	for _, i := range h.index[hashKey] {
+		if Equals(h.entries[i].Key, key) {
+			return hashKey, i, true
+		}
+	}
+	return hashKey, -1, true
+}
+
+// Get returns the value stored under key. The second result is false when
+// the key is missing or not hashable.
+func (h *Hash) Get(key Object) (Object, bool) {
+	_, i, _ := h.lookup(key)
+	if i < 0 {
+		return nil, false
+	}
+	return h.entries[i].Value, true
+}
+
+// Set stores value under key. Replacing the value of an existing key keeps
+// its position. It reports false, leaving the hash untouched, if key is not
+// hashable.
+func (h *Hash) Set(key, value Object) bool {
+	hashKey, i, ok := h.lookup(key)
+	if !ok {
+		return false
+	}
+
+	if i >= 0 {
+		h.entries[i].Value = value
+		return true
+	}
+
+	h.index[hashKey] = append(h.index[hashKey], len(h.entries))
+	h.entries = append(h.entries, HashPair{Key: snapshotKey(key), Value: value})
+	h.size++
+	return true
+}
+
+// Delete removes key from the hash, reporting whether it was present.
+func (h *Hash) Delete(key Object) bool {
+	hashKey, i, _ := h.lookup(key)
+	if i < 0 {
+		return false
+	}
+
+	bucket := h.index[hashKey]
+	for j, pos := range bucket {
+		if pos == i {
+			bucket = append(bucket[:j], bucket[j+1:]...)
+			break
+		}
+	}
+	if len(bucket) == 0 {
+		delete(h.index, hashKey)
+	} else {
+		h.index[hashKey] = bucket
+	}
+
+	h.entries[i] = HashPair{}
+	h.size--
+
+	if len(h.entries) > 8 && h.size < len(h.entries)/2 {
+		h.compact()
+	}
+	return true
+}
+
+// compact drops deleted entries and rebuilds the index.
+func (h *Hash) compact() {
+	entries := h.Pairs()
+	h.entries = entries
+	h.index = make(map[HashKey][]int, len(entries))
+
+	for i, pair := range entries {
+		hashKey, _ := HashKeyOf(pair.Key)
+		h.index[hashKey] = append(h.index[hashKey], i)
+	}
+}
+
+// snapshotKey copies array keys so that mutating the array used to insert
+// a pair cannot change the key stored in the hash.
+func snapshotKey(key Object) Object {
+	arr, ok := key.(*Array)
+	if !ok {
+		return key
+	}
+
+	elements := make([]Object, len(arr.Elements))
+	for i, el := range arr.Elements {
+		elements[i] = snapshotKey(el)
+	}
+	return &Array{Elements: elements}
+}
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
 }
 
+// HashKeyOf returns the hash key of obj. It reports false if obj is not
+// Hashable, or is an array holding a value that is not. NaN is not
+// hashable either, since it does not equal itself and could never be
+// looked up again.
+func HashKeyOf(obj Object) (HashKey, bool) {
+	if !usableAsKey(obj, nil) {
+		return HashKey{}, false
+	}
+	return obj.(Hashable).HashKey(), true
+}
+
+// usableAsKey reports whether obj can be hashed. Arrays that contain
+// themselves cannot, since their key would never finish.
+func usableAsKey(obj Object, parents map[*Array]bool) bool {
+	switch obj := obj.(type) {
+	case *Float:
+		return !math.IsNaN(obj.Value)
+	case *Array:
+		if parents[obj] {
+			return false
+		}
+		if parents == nil {
+			parents = map[*Array]bool{}
+		}
+		parents[obj] = true
+		defer delete(parents, obj)
+		for _, el := range obj.Elements {
+			if !usableAsKey(el, parents) {
+				return false
+			}
+		}
+		return true
+	}
+
+	_, ok := obj.(Hashable)
+	return ok
+}
+
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
 
@@ -86,6 +277,16 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// Whole floats hash like the equal integer, since 1 == 1.0.
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) &&
+		f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return HashKey{Type: INTEGER_OBJ, Value: uint64(int64(f.Value))}
+	}
+
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -93,9 +294,38 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+func (c *Char) HashKey() HashKey {
+	return HashKey{Type: c.Type(), Value: uint64(c.Value)}
+}
+
+func (n *Null) HashKey() HashKey {
+	return HashKey{Type: n.Type()}
+}
+
+// HashKey combines the keys of the elements. Only arrays accepted by
+// HashKeyOf should be used as keys.
+func (ao *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+
+	for _, el := range ao.Elements {
+		var key HashKey
+		if hashable, ok := el.(Hashable); ok {
+			key = hashable.HashKey()
+		}
+		h.Write([]byte(key.Type))
+		binary.LittleEndian.PutUint64(buf, key.Value)
+		h.Write(buf)
+	}
+
+	return HashKey{Type: ao.Type(), Value: h.Sum64()}
+}
+
 // ARRAY
 type Array struct {
 	Elements []Object
+	// Frozen arrays cannot be modified
+	Frozen bool
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
@@ -132,7 +362,9 @@ func (s *String) Inspect() string  { return s.Value }
 
 // FUNCTION
 type Function struct {
-	Parameters []*ast.Identifier
+	// Name is the name of a declared function, and empty otherwise
+	Name       string
+	Parameters []ast.Expression
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -147,6 +379,9 @@ func (f *Function) Inspect() string {
 	}
 
 	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
@@ -159,11 +394,47 @@ func (f *Function) Inspect() string {
 // FD
 type FileDescriptor struct {
 	Value *os.File
+	// Reader buffers reads so that readLine and readAll can be mixed
+	Reader *bufio.Reader
+	Closed bool
+}
+
+func NewFileDescriptor(f *os.File) *FileDescriptor {
+	return &FileDescriptor{Value: f, Reader: bufio.NewReader(f)}
 }
 
-func (f *FileDescriptor) Inspect() string  { return "fd" }
+func (f *FileDescriptor) Inspect() string  { return "fd(" + f.Value.Name() + ")" }
 func (f *FileDescriptor) Type() ObjectType { return FD_OBJ }
 
+// ITERATOR
+//
+// An Iterator lazily produces values. Next returns false once the
+// iterator is exhausted; an *Error value reports a failure.
+type Iterator struct {
+	// Next returns the next value and true, or false once the iterator is
+	// done. An *Error ends the iteration: Next returns false after it.
+	Next func() (Object, bool)
+}
+
+func (i *Iterator) Inspect() string  { return "iterator" }
+func (i *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+
+// CHAR
+type Char struct {
+	Value rune
+}
+
+func (c *Char) Inspect() string  { return string(c.Value) }
+func (c *Char) Type() ObjectType { return CHAR_OBJ }
+
+// REGEX
+type Regex struct {
+	Value *regexp.Regexp
+}
+
+func (r *Regex) Inspect() string  { return "r\"" + r.Value.String() + "\"" }
+func (r *Regex) Type() ObjectType { return REGEX_OBJ }
+
 // FLOAT
 type Float struct {
 	Value float64
@@ -203,10 +474,58 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Kinds of errors raised by the interpreter
+const (
+	ERROR            = "Error"
+	RUNTIME_ERROR    = "RuntimeError"
+	NAME_ERROR       = "NameError"
+	IO_ERROR         = "IOError"
+	PERMISSION_ERROR = "PermissionError"
+	LIMIT_ERROR      = "LimitError"
+)
+
 // ERROR
+//
+// An Error aborts the program until it is caught by a try expression.
 type Error struct {
 	Message string
+	Kind    string
+	// Line and Column locate where the error was raised, zero if unknown
+	Line   int
+	Column int
+	// Stack lists the function calls active when the error was raised,
+	// innermost first
+	Stack []string
+	// Value is the value thrown, nil for errors raised by the interpreter
+	Value Object
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+
+// Report describes the error, where it was raised and its stack, for
+// errors nobody caught.
+func (e *Error) Report() string {
+	var out bytes.Buffer
+
+	if e.Line > 0 {
+		fmt.Fprintf(&out, "line %d, column %d: ", e.Line, e.Column)
+	}
+	out.WriteString(e.Kind + ": " + e.Message)
+	for _, frame := range e.Stack {
+		out.WriteString("\n\tat " + frame)
+	}
+
+	return out.String()
+}
+
+// EXCEPTION
+//
+// An Exception is a caught error, which unlike an Error is an ordinary
+// value.
+type Exception struct {
+	Error *Error
+}
+
+func (e *Exception) Type() ObjectType { return EXCEPTION_OBJ }
+func (e *Exception) Inspect() string  { return e.Error.Kind + ": " + e.Error.Message }
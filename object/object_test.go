@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -20,3 +23,140 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestHashCollisions(t *testing.T) {
+	hash := NewHash()
+	a := &String{Value: "a"}
+	b := &String{Value: "b"}
+
+	// Force both keys into the same bucket to simulate an FNV collision.
+	hashKey := a.HashKey()
+	hash.entries = []HashPair{
+		{Key: b, Value: &Integer{Value: 2}},
+		{Key: a, Value: &Integer{Value: 1}},
+	}
+	hash.index[hashKey] = []int{0, 1}
+	hash.size = 2
+
+	if v, ok := hash.Get(a); !ok || v.(*Integer).Value != 1 {
+		t.Errorf("lookup of colliding key a wrong. got=%v", v)
+	}
+
+	hash.Set(a, &Integer{Value: 3})
+	if hash.Len() != 2 {
+		t.Errorf("setting colliding key changed size. got=%d", hash.Len())
+	}
+
+	if v := hash.entries[0].Value.(*Integer).Value; v != 2 {
+		t.Errorf("colliding key b was overwritten. got=%d", v)
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	hash := NewHash()
+	for i := 0; i < 20; i++ {
+		hash.Set(&Integer{Value: int64(i)}, &Integer{Value: int64(i * i)})
+	}
+
+	for i := 0; i < 20; i += 2 {
+		if !hash.Delete(&Integer{Value: int64(i)}) {
+			t.Fatalf("key %d was not deleted", i)
+		}
+	}
+
+	if hash.Delete(&Integer{Value: 0}) {
+		t.Errorf("deleting a missing key reported true")
+	}
+
+	hash.Set(&Integer{Value: 0}, &Integer{Value: 0})
+	hash.Set(&Integer{Value: 1}, &Integer{Value: 100})
+
+	expected := []int64{1, 3, 5, 7, 9, 11, 13, 15, 17, 19, 0}
+	pairs := hash.Pairs()
+	if len(pairs) != len(expected) || hash.Len() != len(expected) {
+		t.Fatalf("hash has wrong num of pairs. got=%d", len(pairs))
+	}
+
+	for i, pair := range pairs {
+		if pair.Key.(*Integer).Value != expected[i] {
+			t.Errorf("pairs[%d] has wrong key. got=%s, want=%d",
+				i, pair.Key.Inspect(), expected[i])
+		}
+	}
+
+	if v, ok := hash.Get(&Integer{Value: 1}); !ok || v.(*Integer).Value != 100 {
+		t.Errorf("lookup after compaction wrong. got=%v", v)
+	}
+}
+
+func TestFloatHashKey(t *testing.T) {
+	if (&Float{Value: 2.0}).HashKey() != (&Integer{Value: 2}).HashKey() {
+		t.Errorf("whole float and equal integer have different hash keys")
+	}
+
+	if (&Float{Value: 2.5}).HashKey() == (&Float{Value: 2.25}).HashKey() {
+		t.Errorf("floats with different values have same hash keys")
+	}
+
+	nan := &Float{Value: math.NaN()}
+	if _, ok := HashKeyOf(nan); ok {
+		t.Errorf("NaN was accepted as hash key")
+	}
+	if NewHash().Set(nan, &Integer{Value: 1}) {
+		t.Errorf("NaN key was inserted")
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	one := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}}}
+	two := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}}}
+	diff := &Array{Elements: []Object{&String{Value: "x"}, &Integer{Value: 1}}}
+
+	if one.HashKey() != two.HashKey() {
+		t.Errorf("arrays with same content have different hash keys")
+	}
+
+	if one.HashKey() == diff.HashKey() {
+		t.Errorf("arrays with different content have same hash keys")
+	}
+
+	withFn := &Array{Elements: []Object{&Builtin{}}}
+	if _, ok := HashKeyOf(withFn); ok {
+		t.Errorf("array holding a builtin was accepted as hash key")
+	}
+
+	cyclic := &Array{Elements: []Object{&Integer{Value: 1}}}
+	cyclic.Elements = append(cyclic.Elements, cyclic)
+	if _, ok := HashKeyOf(cyclic); ok {
+		t.Errorf("array containing itself was accepted as hash key")
+	}
+
+	shared := &Array{Elements: []Object{one, one}}
+	if _, ok := HashKeyOf(shared); !ok {
+		t.Errorf("array holding the same array twice was rejected as hash key")
+	}
+}
+
+func TestEqualsCycles(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}}}
+	a.Elements = append(a.Elements, a)
+	b := &Array{Elements: []Object{&Integer{Value: 1}}}
+	b.Elements = append(b.Elements, b)
+	c := &Array{Elements: []Object{&Integer{Value: 2}}}
+	c.Elements = append(c.Elements, c)
+
+	if !Equals(a, b) {
+		t.Errorf("arrays containing themselves with equal elements are not equal")
+	}
+	if Equals(a, c) {
+		t.Errorf("arrays containing themselves with different elements are equal")
+	}
+
+	h := NewHash()
+	h.Set(&String{Value: "self"}, h)
+	other := NewHash()
+	other.Set(&String{Value: "self"}, other)
+	if !Equals(h, other) {
+		t.Errorf("hashes containing themselves are not equal")
+	}
+}
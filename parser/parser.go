@@ -5,12 +5,16 @@ import (
 	"Nutlang/lexer"
 	"Nutlang/token"
 	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	TERNARY // c ? a : b
 	OR
 	AND
 	ASSIGN
@@ -21,6 +25,7 @@ const (
 	PRODUCT          // *
 	MODULO           // %
 	PREFIX           // -X or !X
+	POWER            // **
 	CALL             // myFunction(X)
 	INDEX            // X[Y]
 )
@@ -41,8 +46,25 @@ var precedences = map[token.TokenType]int{
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.MODULO:   MODULO,
+	token.POWER:    POWER,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.QUESTION: INDEX,
+
+	token.BITWISEOR:  SUM,
+	token.BITWISEAND: PRODUCT,
+
+	token.PLUS_ASSIGN:       ASSIGN,
+	token.MINUS_ASSIGN:      ASSIGN,
+	token.ASTERISK_ASSIGN:   ASSIGN,
+	token.SLASH_ASSIGN:      ASSIGN,
+	token.MODULO_ASSIGN:     ASSIGN,
+	token.POWER_ASSIGN:      ASSIGN,
+	token.BITWISEAND_ASSIGN: ASSIGN,
+	token.BITWISEOR_ASSIGN:  ASSIGN,
+
+	token.INCREMENT: INDEX,
+	token.DECREMENT: INDEX,
 }
 
 type (
@@ -57,10 +79,25 @@ type Parser struct {
 	curToken       token.Token
 	peekToken      token.Token
 	errors         []string
+
+	// afterPeek, when read, is the token following peekToken, which
+	// tells a ternary c ? a : b from the postfix x?.
+	afterPeek *token.Token
+
+	// scopes holds, for the program and every function literal being
+	// parsed, the names declared in it and whether they are constants.
+	scopes []*scope
+}
+
+type scope struct {
+	names map[string]bool
+	// Binding scopes, of match arms and catch clauses, only hold their
+	// bindings. Other names are declared in the enclosing scope.
+	bindings bool
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []string{}, scopes: []*scope{{names: map[string]bool{}}}}
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
 	p.nextToken()
@@ -71,14 +108,21 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.INCREMENT, p.parsePrefixIncrement)
+	p.registerPrefix(token.DECREMENT, p.parsePrefixIncrement)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.INTERP_START, p.parseInterpolatedString)
+	p.registerPrefix(token.REGEX, p.parseRegexLiteral)
+	p.registerPrefix(token.CHAR, p.parseCharLiteral)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.ELLIPSIS, p.parseSpreadElement)
 	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.FOR, p.parseForExpression)
 
@@ -96,30 +140,184 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GTE, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.QUESTION, p.parseQuestion)
 	p.registerInfix(token.AND, p.parseInfixExpression)
 	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.MODULO, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.BITWISEAND, p.parseInfixExpression)
+	p.registerInfix(token.BITWISEOR, p.parseInfixExpression)
+	p.registerInfix(token.INCREMENT, p.parsePostfixIncrement)
+	p.registerInfix(token.DECREMENT, p.parsePostfixIncrement)
 	p.registerInfix(token.ASSIGN, p.parseAssignmentExpression)
+	p.registerInfix(token.BIND, p.parseAssignmentExpression)
+	for _, tok := range []token.TokenType{
+		token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN,
+		token.SLASH_ASSIGN, token.MODULO_ASSIGN, token.POWER_ASSIGN,
+		token.BITWISEAND_ASSIGN, token.BITWISEOR_ASSIGN,
+	} {
+		p.registerInfix(tok, p.parseAssignmentExpression)
+	}
 
 	return p
 }
 
 func (p *Parser) parseAssignmentExpression(exp ast.Expression) ast.Expression {
+	switch {
+	case p.curTokenIs(token.BIND):
+		// x := y declares x like let x = y
+		if !p.checkPattern(exp, false) {
+			return nil
+		}
+		p.declarePattern(exp, false)
+	case isPattern(exp):
+		if !p.curTokenIs(token.ASSIGN) {
+			p.errors = append(p.errors, fmt.Sprintf("cannot use %s with a destructuring pattern", p.curToken.Literal))
+			return nil
+		}
+		if !p.checkPattern(exp, true) {
+			return nil
+		}
+	default:
+		if !p.checkAssignable(exp) {
+			return nil
+		}
+	}
+
+	ae := &ast.AssignmentExpression{Token: p.curToken, Left: exp}
+	if !p.curTokenIs(token.ASSIGN) && !p.curTokenIs(token.BIND) {
+		ae.Operator = strings.TrimSuffix(p.curToken.Literal, "=")
+	}
+
+	p.nextToken()
+
+	ae.Value = p.parseExpression(LOWEST)
+
+	return ae
+}
+
+// checkAssignable reports whether exp can be assigned to, recording an
+// error otherwise.
+func (p *Parser) checkAssignable(exp ast.Expression) bool {
 	switch node := exp.(type) {
 	case *ast.Identifier, *ast.IndexExpression:
 	default:
 		msg := fmt.Sprintf("expected identifier or index expression on left but got %T %#v", node, exp)
 		p.errors = append(p.errors, msg)
-		return nil
+		return false
 	}
 
-	ae := &ast.AssignmentExpression{Token: p.curToken, Left: exp}
+	if ident, ok := exp.(*ast.Identifier); ok && p.isConstant(ident.Value) {
+		p.errors = append(p.errors, fmt.Sprintf("cannot assign to constant %s", ident.Value))
+	}
+	return true
+}
+
+func isPattern(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.ArrayLiteral, *ast.HashLiteral:
+		return true
+	}
+	return false
+}
+
+// checkPattern reports whether exp can be destructured into, recording
+// an error otherwise. Patterns are identifiers and array or hash literals
+// of patterns, and an array may end with ...rest. The patterns of
+// assignments may also hold index expressions.
+func (p *Parser) checkPattern(exp ast.Expression, assign bool) bool {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if assign && p.isConstant(exp.Value) {
+			p.errors = append(p.errors, fmt.Sprintf("cannot assign to constant %s", exp.Value))
+		}
+		return true
+
+	case *ast.IndexExpression:
+		if assign {
+			return true
+		}
+
+	case *ast.ArrayLiteral:
+		for i, elem := range exp.Elements {
+			if spread, ok := elem.(*ast.SpreadElement); ok {
+				if i != len(exp.Elements)-1 {
+					p.errors = append(p.errors, "rest element must be last in a destructuring pattern")
+					return false
+				}
+				elem = spread.Value
+			}
+			if !p.checkPattern(elem, assign) {
+				return false
+			}
+		}
+		return true
+
+	case *ast.HashLiteral:
+		for _, key := range exp.Keys {
+			if !p.checkPattern(exp.Pairs[key], assign) {
+				return false
+			}
+		}
+		return true
+	}
+
+	p.errors = append(p.errors, fmt.Sprintf("invalid destructuring pattern %s", exp))
+	return false
+}
+
+// declarePattern declares the identifiers bound by a checked pattern.
+func (p *Parser) declarePattern(exp ast.Expression, constant bool) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.declare(exp.Value, constant)
+	case *ast.SpreadElement:
+		p.declarePattern(exp.Value, constant)
+	case *ast.ArrayLiteral:
+		for _, elem := range exp.Elements {
+			p.declarePattern(elem, constant)
+		}
+	case *ast.HashLiteral:
+		for _, key := range exp.Keys {
+			p.declarePattern(exp.Pairs[key], constant)
+		}
+	case *ast.AlternativePattern:
+		for _, pattern := range exp.Patterns {
+			p.declarePattern(pattern, constant)
+		}
+	}
+}
+
+func (p *Parser) parseSpreadElement() ast.Expression {
+	spread := &ast.SpreadElement{Token: p.curToken}
 
 	p.nextToken()
+	spread.Value = p.parseExpression(PREFIX)
 
-	ae.Value = p.parseExpression(LOWEST)
+	return spread
+}
 
-	return ae
+func (p *Parser) parsePrefixIncrement() ast.Expression {
+	expression := &ast.IncrementExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Prefix:   true,
+	}
+
+	p.nextToken()
+	expression.Target = p.parseExpression(PREFIX)
+	if !p.checkAssignable(expression.Target) {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parsePostfixIncrement(target ast.Expression) ast.Expression {
+	if !p.checkAssignable(target) {
+		return nil
+	}
+	return &ast.IncrementExpression{Token: p.curToken, Operator: p.curToken.Literal, Target: target}
 }
 
 func (p *Parser) parseHashLiteral() ast.Expression {
@@ -130,14 +328,22 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		key := p.parseExpression(LOWEST)
 
-		if !p.expectPeek(token.COLON) {
-			return nil
+		var value ast.Expression
+		if ident, ok := key.(*ast.Identifier); ok && (p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.RBRACE)) {
+			// {name} is short for {"name": name}
+			key = &ast.StringLiteral{Token: ident.Token, Value: ident.Value}
+			value = ident
+		} else {
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+
+			p.nextToken()
+			value = p.parseExpression(LOWEST)
 		}
 
-		p.nextToken()
-		value := p.parseExpression(LOWEST)
-
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -151,6 +357,38 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	return hash
 }
 
+// parseQuestion parses the postfix x?, or c ? a : b when an expression
+// follows the question mark.
+func (p *Parser) parseQuestion(left ast.Expression) ast.Expression {
+	if !p.startsExpression(p.peekToken) {
+		return p.parsePostfixExpression(left)
+	}
+
+	expression := &ast.ConditionalExpression{Token: p.curToken, Condition: left}
+
+	p.nextToken()
+	expression.Consequence = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	// Parsing the alternative with LOWEST makes ?: right associative
+	p.nextToken()
+	expression.Alternative = p.parseExpression(LOWEST)
+
+	return expression
+}
+
+func (p *Parser) startsExpression(tok token.Token) bool {
+	_, ok := p.prefixParseFns[tok.Type]
+	return ok
+}
+
+func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
+	return &ast.PostfixExpression{Token: p.curToken, Left: left, Operator: p.curToken.Literal}
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
@@ -200,8 +438,37 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+
+	for {
+		if p.curToken.Literal != "" {
+			str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+		}
+		if p.curTokenIs(token.INTERP_END) {
+			return str
+		}
+
+		p.nextToken()
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+
+		if p.peekTokenIs(token.INTERP_MID) {
+			p.nextToken()
+		} else if !p.expectPeek(token.INTERP_END) {
+			return nil
+		}
+	}
+}
+
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	// match is not a keyword, so that the match builtin can still be
+	// called: the brace after match (x) { ... } tells them apart.
+	if ident.Value == "match" && p.peekTokenIs(token.LPAREN) {
+		return p.parseMatchExpression(ident)
+	}
+	return ident
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -212,8 +479,10 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
+// Errors returns the lexer's errors followed by the parser's own.
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := append([]string{}, p.l.Errors()...)
+	return append(errors, p.errors...)
 }
 
 func (p *Parser) peekError(t token.TokenType) {
@@ -224,7 +493,21 @@ func (p *Parser) peekError(t token.TokenType) {
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	if p.afterPeek != nil {
+		p.peekToken = *p.afterPeek
+		p.afterPeek = nil
+	} else {
+		p.peekToken = p.l.NextToken()
+	}
+}
+
+// peekSecond returns the token after peekToken.
+func (p *Parser) peekSecond() token.Token {
+	if p.afterPeek == nil {
+		tok := p.l.NextToken()
+		p.afterPeek = &tok
+	}
+	return *p.afterPeek
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -242,10 +525,17 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.FUNCTION:
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -253,17 +543,30 @@ func (p *Parser) parseStatement() ast.Statement {
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
+	constant := stmt.Token.Type == token.CONST
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parseExpression(ASSIGN)
+		if !p.checkPattern(stmt.Pattern, false) {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
 
+	if stmt.Pattern != nil {
+		p.declarePattern(stmt.Pattern, constant)
+	} else {
+		p.declare(stmt.Name.Value, constant)
+	}
 	p.nextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
@@ -289,6 +592,23 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -356,6 +676,32 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseCharLiteral() ast.Expression {
+	// The lexer already reported literals that are not a single character
+	if utf8.RuneCountInString(p.curToken.Literal) != 1 {
+		return nil
+	}
+
+	value, _ := utf8.DecodeRuneInString(p.curToken.Literal)
+	return &ast.CharLiteral{Token: p.curToken, Value: value}
+}
+
+func (p *Parser) parseRegexLiteral() ast.Expression {
+	lit := &ast.RegexLiteral{Token: p.curToken}
+
+	value, err := regexp.Compile(p.curToken.Literal)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as regular expression: %s",
+			p.curToken.Literal, err)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
@@ -390,6 +736,9 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) peekPrecedence() int {
+	if p.peekTokenIs(token.QUESTION) && p.startsExpression(p.peekSecond()) {
+		return TERNARY
+	}
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
 	}
@@ -413,6 +762,10 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := p.curPrecedence()
+	if precedence == POWER {
+		// 2 ** 3 ** 2 is 2 ** (3 ** 2)
+		precedence--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 
@@ -423,18 +776,76 @@ func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+// parseGroupedExpression parses (exp), or the lambda (params) => body.
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	tok := p.curToken
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		return p.parseArrowFunction(tok, nil)
+	}
+
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
+	exps := []ast.Expression{exp}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		exps = append(exps, p.parseExpression(LOWEST))
+	}
 
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
 
+	if p.peekTokenIs(token.ARROW) {
+		p.nextToken()
+		return p.parseArrowFunction(tok, exps)
+	}
+	if len(exps) > 1 {
+		p.peekError(token.ARROW)
+		return nil
+	}
+
 	return exp
 }
 
+// parseArrowFunction parses the body of (params) => body into the same
+// function literal as fn(params) { body }.
+func (p *Parser) parseArrowFunction(tok token.Token, params []ast.Expression) ast.Expression {
+	lit := &ast.FunctionLiteral{
+		Token:      token.Token{Type: token.FUNCTION, Literal: "fn", Line: tok.Line, Column: tok.Column},
+		Parameters: []ast.Expression{},
+	}
+	for _, param := range params {
+		if !p.checkPattern(param, false) {
+			return nil
+		}
+		lit.Parameters = append(lit.Parameters, param)
+	}
+
+	p.nextToken()
+	bodyToken := p.curToken
+
+	p.pushScope(false, lit.Parameters...)
+	body := p.parseExpression(LOWEST)
+	p.popScope()
+
+	if body == nil {
+		return nil
+	}
+
+	lit.Body = &ast.BlockStatement{
+		Token:      bodyToken,
+		Statements: []ast.Statement{&ast.ExpressionStatement{Token: bodyToken, Expression: body}},
+	}
+	return lit
+}
+
 func (p *Parser) parseForExpression() ast.Expression {
 	tok := p.curToken
 
@@ -502,6 +913,16 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			elseIf, ok := p.parseIfExpression().(*ast.IfExpression)
+			if !ok {
+				return nil
+			}
+			expression.ElseIf = elseIf
+			return expression
+		}
+
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -512,6 +933,275 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			expression.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		// The parameter is only visible in the catch block
+		if expression.Param != nil {
+			p.pushScope(true, expression.Param)
+		} else {
+			p.pushScope(true)
+		}
+		expression.Catch = p.parseBlockStatement()
+		p.popScope()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.errors = append(p.errors, "expected catch or finally after try block")
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseMatchExpression(ident *ast.Identifier) ast.Expression {
+	p.nextToken()
+	call := &ast.CallExpression{Token: p.curToken, Function: ident}
+	call.Arguments = p.parseExpressionList(token.RPAREN)
+
+	if !p.peekTokenIs(token.LBRACE) {
+		return call
+	}
+	if len(call.Arguments) != 1 {
+		p.errors = append(p.errors, "match expects a single value")
+		return nil
+	}
+
+	expression := &ast.MatchExpression{Token: ident.Token, Value: call.Arguments[0]}
+	p.nextToken()
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+
+		// Like in hash literals arms are separated by commas, which may
+		// be left out after a block
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		} else if _, ok := arm.Result.(*ast.BlockStatement); !ok && !p.peekTokenIs(token.RBRACE) {
+			p.peekError(token.COMMA)
+			return nil
+		}
+	}
+	p.nextToken()
+
+	return expression
+}
+
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parseMatchPattern()}
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	// The bindings are only visible in the guard and result of the arm
+	p.pushScope(true, arm.Pattern)
+	defer p.popScope()
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	p.nextToken()
+
+	if p.curTokenIs(token.LBRACE) {
+		arm.Result = p.parseBlockStatement()
+	} else {
+		arm.Result = p.parseExpression(LOWEST)
+	}
+	if arm.Result == nil {
+		return nil
+	}
+
+	return arm
+}
+
+// parseMatchPattern parses a pattern of a match arm, which may be a list
+// of alternatives separated by |.
+func (p *Parser) parseMatchPattern() ast.Expression {
+	pattern := p.parsePatternPrimary()
+	if pattern == nil || !p.peekTokenIs(token.BITWISEOR) {
+		return pattern
+	}
+
+	alternatives := &ast.AlternativePattern{Token: p.peekToken, Patterns: []ast.Expression{pattern}}
+	for p.peekTokenIs(token.BITWISEOR) {
+		p.nextToken()
+		p.nextToken()
+		pattern := p.parsePatternPrimary()
+		if pattern == nil {
+			return nil
+		}
+		alternatives.Patterns = append(alternatives.Patterns, pattern)
+	}
+
+	return alternatives
+}
+
+func (p *Parser) parsePatternPrimary() ast.Expression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+
+	case token.LBRACE:
+		return p.parseHashPattern()
+
+	case token.INT, token.FLOAT, token.STRING, token.CHAR, token.TRUE, token.FALSE, token.MINUS:
+		literal := p.parseLiteralPattern()
+		if literal == nil || !(p.peekTokenIs(token.RANGE) || p.peekTokenIs(token.RANGE_INC)) {
+			return literal
+		}
+
+		p.nextToken()
+		pattern := &ast.RangePattern{Token: p.curToken, Low: literal, Inclusive: p.curTokenIs(token.RANGE_INC)}
+		p.nextToken()
+		pattern.High = p.parseLiteralPattern()
+		if pattern.High == nil {
+			return nil
+		}
+		return pattern
+	}
+
+	p.errors = append(p.errors, fmt.Sprintf("invalid match pattern %s", p.curToken.Literal))
+	return nil
+}
+
+// parseLiteralPattern parses a literal, or a negative number.
+func (p *Parser) parseLiteralPattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.INT, token.FLOAT, token.STRING, token.CHAR, token.TRUE, token.FALSE:
+		return p.prefixParseFns[p.curToken.Type]()
+
+	case token.MINUS:
+		if p.peekTokenIs(token.INT) || p.peekTokenIs(token.FLOAT) {
+			return p.parsePrefixExpression()
+		}
+	}
+
+	p.errors = append(p.errors, fmt.Sprintf("invalid match pattern %s", p.curToken.Literal))
+	return nil
+}
+
+func (p *Parser) parseArrayPattern() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken, Elements: []ast.Expression{}}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			spread := &ast.SpreadElement{Token: p.curToken}
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			spread.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			array.Elements = append(array.Elements, spread)
+
+			if !p.peekTokenIs(token.RBRACKET) {
+				p.errors = append(p.errors, "rest element must be last in a destructuring pattern")
+				return nil
+			}
+			break
+		}
+
+		elem := p.parseMatchPattern()
+		if elem == nil {
+			return nil
+		}
+		array.Elements = append(array.Elements, elem)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return array
+}
+
+func (p *Parser) parseHashPattern() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: map[ast.Expression]ast.Expression{}}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		var key, value ast.Expression
+		if p.curTokenIs(token.IDENT) && (p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.RBRACE)) {
+			// {name} is short for {"name": name}
+			key = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+			value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		} else {
+			key = p.parseLiteralPattern()
+			if key == nil || !p.expectPeek(token.COLON) {
+				return nil
+			}
+			p.nextToken()
+			value = p.parseMatchPattern()
+			if value == nil {
+				return nil
+			}
+		}
+
+		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return hash
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -529,26 +1219,102 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	return block
 }
 
+// parseFunctionStatement parses the declaration fn name(params) { body }.
+func (p *Parser) parseFunctionStatement() ast.Statement {
+	stmt := &ast.FunctionStatement{Token: p.curToken}
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	lit.Name = stmt.Name.Value
+	p.declare(stmt.Name.Value, false)
+
+	if !p.parseFunction(lit) {
+		return nil
+	}
+	stmt.Function = lit
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
+	if !p.parseFunction(lit) {
+		return nil
+	}
+	return lit
+}
 
+// parseFunction parses the parameters and body of lit, reporting whether
+// it succeeded.
+func (p *Parser) parseFunction(lit *ast.FunctionLiteral) bool {
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return false
 	}
 
 	lit.Parameters = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return false
 	}
 
+	p.pushScope(false, lit.Parameters...)
 	lit.Body = p.parseBlockStatement()
+	p.popScope()
 
-	return lit
+	return true
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// pushScope starts a scope declaring the identifiers bound by patterns,
+// which is a binding scope if bindings is true.
+func (p *Parser) pushScope(bindings bool, patterns ...ast.Expression) {
+	p.scopes = append(p.scopes, &scope{names: map[string]bool{}})
+	for _, pattern := range patterns {
+		p.declarePattern(pattern, false)
+	}
+	p.scopes[len(p.scopes)-1].bindings = bindings
+}
+
+func (p *Parser) popScope() {
+	p.scopes = p.scopes[:len(p.scopes)-1]
+}
+
+// declare records the declaration of name in the current scope, or the
+// scope enclosing binding scopes that do not bind name.
+func (p *Parser) declare(name string, constant bool) {
+	i := len(p.scopes) - 1
+	for p.scopes[i].bindings {
+		if _, ok := p.scopes[i].names[name]; ok {
+			break
+		}
+		i--
+	}
+
+	scope := p.scopes[i].names
+	if scope[name] {
+		p.errors = append(p.errors, fmt.Sprintf("cannot redeclare constant %s", name))
+	}
+	scope[name] = constant
+}
+
+// isConstant reports whether name refers to a constant declared in the
+// scopes parsed so far. Names declared elsewhere, such as in a previous
+// line of the REPL, are checked at runtime instead.
+func (p *Parser) isConstant(name string) bool {
+	for i := len(p.scopes) - 1; i >= 0; i-- {
+		if constant, ok := p.scopes[i].names[name]; ok {
+			return constant
+		}
+	}
+	return false
+}
+
+func (p *Parser) parseFunctionParameters() []ast.Expression {
+	identifiers := []ast.Expression{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
@@ -556,15 +1322,12 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	}
 
 	p.nextToken()
-
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	identifiers = append(identifiers, p.parseFunctionParameter())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		identifiers = append(identifiers, p.parseFunctionParameter())
 	}
 
 	if !p.expectPeek(token.RPAREN) {
@@ -574,6 +1337,19 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	return identifiers
 }
 
+// parseFunctionParameter parses an identifier, or a pattern destructuring
+// the argument as in fn([x, y]) { ... }.
+func (p *Parser) parseFunctionParameter() ast.Expression {
+	if p.curTokenIs(token.LBRACKET) || p.curTokenIs(token.LBRACE) {
+		pattern := p.parseExpression(ASSIGN)
+		if !p.checkPattern(pattern, false) {
+			return nil
+		}
+		return pattern
+	}
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
@@ -4,6 +4,7 @@ import (
 	"Nutlang/ast"
 	"Nutlang/lexer"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -107,6 +108,12 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 
 		testIntegerLiteral(t, value, expectedValue)
 	}
+
+	for i, key := range []string{"one", "two", "three"} {
+		if hash.Keys[i].String() != key {
+			t.Errorf("hash.Keys[%d] wrong. want=%q, got=%q", i, key, hash.Keys[i])
+		}
+	}
 }
 
 func TestParsingIndexExpressions(t *testing.T) {
@@ -174,6 +181,351 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringExpression(t *testing.T) {
+	input := `"pos=${x},${y + 1}!"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(str.Parts) != 5 {
+		t.Fatalf("str.Parts has wrong length. got=%d", len(str.Parts))
+	}
+
+	for i, expected := range []string{"pos=", "", ",", "", "!"} {
+		if expected == "" {
+			continue
+		}
+		literal, ok := str.Parts[i].(*ast.StringLiteral)
+		if !ok || literal.Value != expected {
+			t.Errorf("str.Parts[%d] is not %q. got=%s", i, expected, str.Parts[i])
+		}
+	}
+
+	testIdentifier(t, str.Parts[1], "x")
+	testInfixExpression(t, str.Parts[3], "y", "+", 1)
+
+	if str.String() != "pos=${x},${(y + 1)}!" {
+		t.Errorf("str.String() wrong. got=%q", str.String())
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	l := lexer.New(`"a${x"`)
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors for unterminated interpolation")
+	}
+}
+
+func TestRegexLiteralExpression(t *testing.T) {
+	input := `r"(\d+)-\"x\""`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.RegexLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.RegexLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value.String() != `(\d+)-\"x\"` {
+		t.Errorf("literal.Value not %q. got=%q", `(\d+)-\"x\"`, literal.Value.String())
+	}
+
+	p = New(lexer.New(`r"(a"`))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 {
+		t.Errorf("expected one error for invalid regex. got=%v", p.Errors())
+	}
+}
+
+func TestCharLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected rune
+	}{
+		{`'a'`, 'a'},
+		{`'\n'`, '\n'},
+		{`'\''`, '\''},
+		{`'é'`, 'é'},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.CharLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.CharLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %q. got=%q", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	l := lexer.New(`throw "oops";`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+			len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+	if stmt.String() != `throw oops;` {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input      string
+		param      string
+		hasCatch   bool
+		hasFinally bool
+	}{
+		{`try { x } catch (e) { y }`, "e", true, false},
+		{`try { x } catch { y }`, "", true, false},
+		{`try { x } finally { z }`, "", false, true},
+		{`try { x } catch (err) { y } finally { z }`, "err", true, true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T", stmt.Expression)
+		}
+		if !testIdentifier(t, exp.Body.Statements[0].(*ast.ExpressionStatement).Expression, "x") {
+			return
+		}
+		if tt.param == "" && exp.Param != nil {
+			t.Errorf("exp.Param should be nil. got=%s", exp.Param)
+		}
+		if tt.param != "" && (exp.Param == nil || exp.Param.Value != tt.param) {
+			t.Errorf("exp.Param is not %s. got=%v", tt.param, exp.Param)
+		}
+		if (exp.Catch != nil) != tt.hasCatch {
+			t.Errorf("exp.Catch wrong. got=%v", exp.Catch)
+		}
+		if (exp.Finally != nil) != tt.hasFinally {
+			t.Errorf("exp.Finally wrong. got=%v", exp.Finally)
+		}
+	}
+
+	p := New(lexer.New(`try { x }`))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected an error for try without catch or finally")
+	}
+}
+
+func TestPostfixExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`x?`, `(x?)`},
+		{`readFile(p)?`, `(readFile(p)?)`},
+		{`a + b[0]?`, `(a + ((b[0])?))`},
+		{`-x?`, `(-(x?))`},
+		{`f(x?)`, `f((x?))`},
+		{`x? + 1`, `((x?) + 1)`},
+		{`[x?, y?]`, `[(x?), (y?)]`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestConditionalExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`c ? a : b`, `(c ? a : b)`},
+		{`a < b ? -1 : 1`, `((a < b) ? (-1) : 1)`},
+		{`a || b ? c + 1 : d * 2`, `((a || b) ? (c + 1) : (d * 2))`},
+		{`a ? b : c ? d : e`, `(a ? b : (c ? d : e))`},
+		{`x = c ? 1 : 2`, `(x=(c ? 1 : 2))`},
+		{`f(x?) ? y? : z`, `(f((x?)) ? (y?) : z)`},
+		{`{"k": c ? 1 : 2}`, `{k:(c ? 1 : 2)}`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New(`c ? a`))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "expected next token to be :, got EOF instead" {
+		t.Errorf("wrong errors for a ternary without alternative. got=%v", p.Errors())
+	}
+}
+
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < 0) { a } else if (x == 0) { b } else if (x < 10) { c } else { d }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("expression is not ast.IfExpression. got=%T", program.Statements[0])
+	}
+
+	conditions := []string{}
+	for exp.ElseIf != nil {
+		if exp.Alternative != nil {
+			t.Fatalf("if has both an else if and an else")
+		}
+		conditions = append(conditions, exp.Condition.String())
+		exp = exp.ElseIf
+	}
+	conditions = append(conditions, exp.Condition.String())
+
+	if strings.Join(conditions, ", ") != "(x < 0), (x == 0), (x < 10)" {
+		t.Errorf("wrong conditions. got=%v", conditions)
+	}
+	if exp.Alternative == nil || exp.Alternative.String() != "d" {
+		t.Errorf("wrong final else. got=%v", exp.Alternative)
+	}
+}
+
+func TestFunctionStatement(t *testing.T) {
+	input := `fn add(x, [y, z]) { x + y + z } fn(x) { x }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program has wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.FunctionStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Name.Value != "add" || stmt.Function.Name != "add" {
+		t.Errorf("wrong function name. got=%q", stmt.Name.Value)
+	}
+	if stmt.String() != "fn add(x, [y, z]) ((x + y) + z)" {
+		t.Errorf("wrong String(). got=%q", stmt.String())
+	}
+
+	if _, ok := program.Statements[1].(*ast.ExpressionStatement); !ok {
+		t.Errorf("anonymous function is not an expression. got=%T", program.Statements[1])
+	}
+
+	p = New(lexer.New(`const f = 1; fn f() { 2 }`))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "cannot redeclare constant f" {
+		t.Errorf("wrong errors. got=%v", p.Errors())
+	}
+}
+
+func TestArrowFunction(t *testing.T) {
+	tests := []struct {
+		input    string
+		params   []string
+		expected string
+	}{
+		{`(x) => x * 2`, []string{"x"}, "fn(x) (x * 2)"},
+		{`(a, b) => a + b`, []string{"a", "b"}, "fn(a, b) (a + b)"},
+		{`() => 5`, []string{}, "fn() 5"},
+		{`([x, y], {z}) => x`, []string{"[x, y]", "{z:z}"}, "fn([x, y], {z:z}) x"},
+		{`(x) => (y) => x + y`, []string{"x"}, "fn(x) fn(y) (x + y)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+		}
+		if len(function.Parameters) != len(tt.params) {
+			t.Fatalf("wrong parameters for %q. got=%v", tt.input, function.Parameters)
+		}
+		for i, param := range tt.params {
+			if function.Parameters[i].String() != param {
+				t.Errorf("wrong parameter %d for %q. want=%q, got=%q", i, tt.input, param, function.Parameters[i])
+			}
+		}
+		if function.String() != tt.expected {
+			t.Errorf("wrong String() for %q. want=%q, got=%q", tt.input, tt.expected, function.String())
+		}
+	}
+
+	program := New(lexer.New(`map(xs, (x) => x * 2, 1)`)).ParseProgram()
+	if program.String() != "map(xs, fn(x) (x * 2), 1)" {
+		t.Errorf("wrong lambda argument. got=%q", program.String())
+	}
+
+	errorTests := []struct {
+		input string
+		msg   string
+	}{
+		{`(a, b)`, "expected next token to be =>, got EOF instead"},
+		{`(1) => 1`, "invalid destructuring pattern 1"},
+		{`()`, "expected next token to be =>, got EOF instead"},
+	}
+
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.msg {
+			t.Errorf("wrong errors for %q. want=%q, got=%v", tt.input, tt.msg, p.Errors())
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input              string
@@ -208,6 +560,200 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input  string
+		errors []string
+	}{
+		{`const x = 1; x`, nil},
+		{`const x = 1; x = 2`, []string{"cannot assign to constant x"}},
+		{`const x = 1; let x = 2`, []string{"cannot redeclare constant x"}},
+		{`const x = 1; const x = 2`, []string{"cannot redeclare constant x"}},
+		{`const x = 1; let f = fn() { x = 2 }`, []string{"cannot assign to constant x"}},
+		{`const x = 1; let f = fn(x) { x = 2 }`, nil},
+		{`const x = 1; let f = fn() { let x = 0; x = 2 }`, nil},
+		{`const e = 1; try { x } catch (e) { e = 2 }`, nil},
+		{`const e = 1; try { x } catch (e) { 0 }; e = 2`, []string{"cannot assign to constant e"}},
+		{`const y = 1; try { x } catch (e) { let y = 2 }`, []string{"cannot redeclare constant y"}},
+		{`try { x } catch (e) { const y = 1 }; y = 2`, []string{"cannot assign to constant y"}},
+		{`let x = 1; x = 2`, nil},
+		{`const xs = [1]; xs[0] = 2`, nil},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != len(tt.errors) {
+			t.Errorf("wrong errors for %q. want=%v, got=%v", tt.input, tt.errors, p.Errors())
+			continue
+		}
+		for i, msg := range tt.errors {
+			if p.Errors()[i] != msg {
+				t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, msg, p.Errors()[i])
+			}
+		}
+		if len(tt.errors) == 0 && program.Statements[0].String() != strings.Split(tt.input, ";")[0]+";" {
+			t.Errorf("wrong String() for %q. got=%q", tt.input, program.Statements[0].String())
+		}
+	}
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		prefix   bool
+	}{
+		{"x += 1", "+", false},
+		{"x **= 2", "**", false},
+		{"x |= 4", "|", false},
+		{"a[0] %= 3", "%", false},
+		{"x = 1", "", false},
+		{"x++", "++", false},
+		{"--a[0]", "--", true},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		switch exp := stmt.Expression.(type) {
+		case *ast.AssignmentExpression:
+			if exp.Operator != tt.operator {
+				t.Errorf("wrong operator for %q. want=%q, got=%q", tt.input, tt.operator, exp.Operator)
+			}
+		case *ast.IncrementExpression:
+			if exp.Operator != tt.operator || exp.Prefix != tt.prefix {
+				t.Errorf("wrong increment for %q. got=%q prefix=%t", tt.input, exp.Operator, exp.Prefix)
+			}
+		default:
+			t.Errorf("unexpected expression for %q: %T", tt.input, exp)
+		}
+	}
+
+	errorTests := []struct {
+		input string
+		msg   string
+	}{
+		{"const x = 1; x += 2", "cannot assign to constant x"},
+		{"const x = 1; x++", "cannot assign to constant x"},
+		{"1++", "expected identifier or index expression on left but got *ast.IntegerLiteral"},
+	}
+
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || !strings.HasPrefix(p.Errors()[0], tt.msg) {
+			t.Errorf("wrong errors for %q. want=%q, got=%v", tt.input, tt.msg, p.Errors())
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [x, y] = p;", "let [x, y] = p;"},
+		{"const [head, ...tail] = arr;", "const [head, ...tail] = arr;"},
+		{"let {name, age} = h;", "let {name:name, age:age} = h;"},
+		{`let {"pos": [x, y]} = h;`, "let {pos:[x, y]} = h;"},
+		{"[a, b] = [b, a]", "([a, b]=[b, a])"},
+		{"[a[0], a[1]] = [a[1], a[0]]", "([(a[0]), (a[1])]=[(a[1]), (a[0])])"},
+		{"x := 5", "(x:=5)"},
+		{"[q, r] := divmod(7, 2)", "([q, r]:=divmod(7, 2))"},
+		{"fn([x, y], {z}) { x }", "fn([x, y], {z:z}) x"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("wrong program for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+
+	errorTests := []struct {
+		input string
+		msg   string
+	}{
+		{"let [x, 1] = p", "invalid destructuring pattern 1"},
+		{"let [...xs, y] = p", "rest element must be last in a destructuring pattern"},
+		{"let [a[0]] = p", "invalid destructuring pattern (a[0])"},
+		{"[a, b] += [1, 2]", "cannot use += with a destructuring pattern"},
+		{"const c = 1; [c, d] = [1, 2]", "cannot assign to constant c"},
+		{"const c = 1; [c, d] := [1, 2]", "cannot redeclare constant c"},
+		{"fn([x, 1]) { x }", "invalid destructuring pattern 1"},
+	}
+
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.msg {
+			t.Errorf("wrong errors for %q. want=%q, got=%v", tt.input, tt.msg, p.Errors())
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match (x) { 1 => "one", _ => "other" }`, "match (x) { 1 => one, _ => other }"},
+		{`match (x) { -1 | 0 => a, 1..5 => b, 5..=9 => c, }`, "match (x) { (-1) | 0 => a, 1..5 => b, 5..=9 => c }"},
+		{`match (p) { [0, y] => y, [x, ...rest] if x > 0 => rest }`, "match (p) { [0, y] => y, [x, ...rest] if (x > 0) => rest }"},
+		{`match (h) { {"kind": "circle", r} => r * r }`, "match (h) { {kind:circle, r:r} => (r * r) }"},
+		{`match (c) { 'a'..='z' => { 1 } _ => 0 }`, "match (c) { 'a'..='z' => 1, _ => 0 }"},
+		{`match(re, s)`, "match(re, s)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("wrong program for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+
+	errorTests := []struct {
+		input string
+		msg   string
+	}{
+		{`match (x) { x + 1 => 1 }`, "expected next token to be =>, got + instead"},
+		{`match (x) { (1) => 1 }`, "invalid match pattern ("},
+		{`match (x) { [...1] => 1 }`, "expected next token to be IDENT, got INT instead"},
+		{`match (x) { [...a, b] => 1 }`, "rest element must be last in a destructuring pattern"},
+		{`match (x) { 1 => 1 2 => 2 }`, "expected next token to be ,, got INT instead"},
+		{`match (x, y) { _ => 1 }`, "match expects a single value"},
+		{`const x = 1; match (2) { x => x, _ => 0 }; let x = 3`, "cannot redeclare constant x"},
+		{`const y = 1; match (2) { x => { y = x } }`, "cannot assign to constant y"},
+		{`match (2) { x => { const y = x } }; y = 3`, "cannot assign to constant y"},
+	}
+
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		found := false
+		for _, msg := range p.Errors() {
+			found = found || msg == tt.msg
+		}
+		if !found {
+			t.Errorf("wrong errors for %q. want=%q, got=%v", tt.input, tt.msg, p.Errors())
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -547,6 +1093,26 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"2 ** 3 ** 2",
+			"(2 ** (3 ** 2))",
+		},
+		{
+			"-a ** 2 * b",
+			"((-(a ** 2)) * b)",
+		},
+		{
+			"a | b & c + d",
+			"((a | (b & c)) + d)",
+		},
+		{
+			"x += a * b",
+			"(x+=(a * b))",
+		},
+		{
+			"a[i++] -= --b",
+			"((a[(i++)])-=(--b))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -5,25 +5,30 @@ import (
 	"Nutlang/lexer"
 	"Nutlang/object"
 	"Nutlang/parser"
-	"bufio"
 	"fmt"
 	"io"
+	"strings"
 )
 
 const PROMPT = ">> "
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	// Programs share in with the REPL, so that readLine() reads the next
+	// line typed after the prompt.
+	streams := object.NewStreams(in, out, out)
+	env := object.NewEnvironmentWithStreams(streams)
+	// Like scripts, the lines typed in may use every capability
+	env.Runtime().Permissions.Only(object.Capabilities...)
+	defer env.Runtime().CloseFiles()
 
 	for {
 		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		line, err := streams.Stdin.ReadString('\n')
+		if err != nil && line == "" {
 			return
 		}
 
-		line := scanner.Text()
+		line = strings.TrimRight(line, "\r\n")
 		l := lexer.New(line)
 		p := parser.New(l)
 
@@ -33,14 +38,40 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
+		// Every line gets a fresh budget
+		env.Runtime().Reset()
 		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
+		if errObj, ok := evaluated.(*object.Error); ok {
+			io.WriteString(out, "ERROR: "+errObj.Report()+"\n")
+		} else if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
 		}
 	}
 }
 
+// Run evaluates a whole program, as when running a script file, and
+// reports whether it finished without errors. Errors are written to
+// the program's standard error.
+func Run(src string, runtime *object.Runtime) bool {
+	streams := runtime.Streams
+	defer runtime.CloseFiles()
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(streams.Stderr, p.Errors())
+		return false
+	}
+
+	evaluated := evaluator.Eval(program, object.NewEnvironmentWithRuntime(runtime))
+	if errObj, ok := evaluated.(*object.Error); ok {
+		io.WriteString(streams.Stderr, errObj.Report()+"\n")
+		return false
+	}
+	return true
+}
+
 func printParserErrors(out io.Writer, errors []string) {
 	for _, msg := range errors {
 		io.WriteString(out, "\t"+msg+"\n")
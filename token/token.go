@@ -5,6 +5,9 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	// Line and Column locate the start of the token, both from 1
+	Line   int
+	Column int
 }
 
 const (
@@ -16,6 +19,14 @@ const (
 	INT     = "INT"
 	FLOAT   = "FLOAT"
 	COMMENT = "COMMENT"
+	REGEX   = "REGEX" // r"[a-z]+\d"
+	CHAR    = "CHAR"  // 'a'
+
+	// Interpolated strings, "a${x}b${y}c", are lexed as INTERP_START "a",
+	// the tokens of x, INTERP_MID "b", the tokens of y and INTERP_END "c".
+	INTERP_START = "INTERP_START"
+	INTERP_MID   = "INTERP_MID"
+	INTERP_END   = "INTERP_END"
 
 	// Operators
 	BIND     = ":="
@@ -26,6 +37,20 @@ const (
 	ASTERISK = "*"
 	SLASH    = "/"
 	MODULO   = "%"
+	POWER    = "**"
+
+	// Compound assignments, x += y is x = x + y
+	PLUS_ASSIGN       = "+="
+	MINUS_ASSIGN      = "-="
+	ASTERISK_ASSIGN   = "*="
+	SLASH_ASSIGN      = "/="
+	MODULO_ASSIGN     = "%="
+	POWER_ASSIGN      = "**="
+	BITWISEAND_ASSIGN = "&="
+	BITWISEOR_ASSIGN  = "|="
+
+	INCREMENT = "++"
+	DECREMENT = "--"
 
 	LT  = "<"
 	LTE = "<="
@@ -43,7 +68,12 @@ const (
 	// Delimiters
 	COMMA     = ","
 	DOT       = "."
+	ELLIPSIS  = "..."
+	RANGE     = ".."  // 1..5 excludes 5
+	RANGE_INC = "..=" // 1..=5 includes it
+	ARROW     = "=>"
 	COLON     = ":"
+	QUESTION  = "?"
 	SEMICOLON = ";"
 	LPAREN    = "("
 	RPAREN    = ")"
@@ -55,6 +85,7 @@ const (
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	RETURN   = "RETURN"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
@@ -62,17 +93,26 @@ const (
 	IF       = "IF"
 	STRING   = "STRING"
 	FOR      = "FOR"
+	THROW    = "THROW"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"for":    FOR,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"const":   CONST,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"for":     FOR,
+	"throw":   THROW,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
 }
 
 func LookupIdent(ident string) TokenType {